@@ -0,0 +1,396 @@
+package airspace
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paulmach/orb"
+	"gopkg.in/yaml.v2"
+)
+
+// ActivationKind says how the times at which a volume is active are determined.
+type ActivationKind string
+
+const (
+	// Always active. This is the default, and what a zero ActivationKind means.
+	ActivationH24 ActivationKind = "H24"
+	// Active during the published Hours.
+	ActivationHours ActivationKind = "HOURS"
+	// Activated by NOTAM. Without Windows (e.g. taken from the NOTAMs themselves) we can't know
+	// when, so the volume is assumed to be active.
+	ActivationNOTAM ActivationKind = "NOTAM"
+	// Active only during the given Windows.
+	ActivationWindows ActivationKind = "WINDOWS"
+)
+
+// Activation describes when a volume is active. Times are UTC, as in the AIP.
+type Activation struct {
+	Kind    ActivationKind
+	Hours   []DailyHours `json:",omitempty"`
+	Windows []TimeWindow `json:",omitempty"`
+}
+
+// SolarEvent is the event a TimeOfDay is relative to.
+type SolarEvent string
+
+const (
+	Midnight SolarEvent = ""   // Clock time, i.e. relative to 00:00 UTC.
+	Sunrise  SolarEvent = "SR" // Relative to sunrise.
+	Sunset   SolarEvent = "SS" // Relative to sunset.
+)
+
+// TimeOfDay is a time, e.g. 0800 or SR-30, on a given day.
+type TimeOfDay struct {
+	Event  SolarEvent
+	Offset time.Duration
+}
+
+// DailyHours is a period of activity on certain days of the week, such as "MON-FRI 0800-1700"
+// or "SR-30 to SS+30". A period whose end is before its start continues into the next day.
+type DailyHours struct {
+	Days  []time.Weekday `json:",omitempty"` // Empty means every day.
+	Start TimeOfDay
+	End   TimeOfDay
+}
+
+func (t TimeOfDay) String() string {
+	if t.Event == Midnight {
+		return fmt.Sprintf("%02d%02d", int(t.Offset.Hours()), int(t.Offset.Minutes())%60)
+	}
+	switch {
+	case t.Offset > 0:
+		return fmt.Sprintf("%s+%d", t.Event, int(t.Offset.Minutes()))
+	case t.Offset < 0:
+		return fmt.Sprintf("%s-%d", t.Event, int(-t.Offset.Minutes()))
+	default:
+		return string(t.Event)
+	}
+}
+
+// on returns the time on the UTC day `day`, at point `p`. It returns false if the sun does not
+// rise or set that day.
+func (t TimeOfDay) on(day time.Time, p orb.Point) (time.Time, bool) {
+	switch t.Event {
+	case Sunrise, Sunset:
+		rise, set, ok := sunriseSunset(day, p)
+		if !ok {
+			return time.Time{}, false
+		}
+		if t.Event == Sunrise {
+			return rise.Add(t.Offset), true
+		}
+		return set.Add(t.Offset), true
+	default:
+		return day.Add(t.Offset), true
+	}
+}
+
+// ActiveAt reports whether the volume is both valid (see ValidAt) and active at time `t`.
+// Sunrise and sunset are calculated for the centre of the volume.
+func (v Volume) ActiveAt(t time.Time) bool {
+	return v.ValidAt(t) && v.Activation.activeAt(t, volumeBound(v).Center())
+}
+
+func (a Activation) activeAt(t time.Time, p orb.Point) bool {
+	switch a.Kind {
+	case ActivationHours:
+		for _, h := range a.Hours {
+			if h.activeAt(t, p) {
+				return true
+			}
+		}
+		return false
+	case ActivationNOTAM:
+		return len(a.Windows) == 0 || inWindows(t, a.Windows)
+	case ActivationWindows:
+		return inWindows(t, a.Windows)
+	default:
+		return true
+	}
+}
+
+func inWindows(t time.Time, windows []TimeWindow) bool {
+	for _, w := range windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h DailyHours) activeAt(t time.Time, p orb.Point) bool {
+	t = t.UTC()
+	today := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	// A period that started yesterday may not have finished yet.
+	for _, day := range []time.Time{today.AddDate(0, 0, -1), today} {
+		if !h.onDay(day.Weekday()) {
+			continue
+		}
+		start, ok1 := h.Start.on(day, p)
+		end, ok2 := h.End.on(day, p)
+		if !ok1 || !ok2 {
+			continue
+		}
+		if !end.After(start) {
+			if end, ok2 = h.End.on(day.AddDate(0, 0, 1), p); !ok2 {
+				continue
+			}
+		}
+		if (TimeWindow{Start: start, End: end}).Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h DailyHours) onDay(d time.Weekday) bool {
+	if len(h.Days) == 0 {
+		return true
+	}
+	for _, day := range h.Days {
+		if day == d {
+			return true
+		}
+	}
+	return false
+}
+
+// rulesActivation returns the activation implied by a yaixm feature's or volume's rules.
+func rulesActivation(rules []string) Activation {
+	for _, r := range rules {
+		if strings.EqualFold(r, "NOTAM") {
+			return Activation{Kind: ActivationNOTAM}
+		}
+	}
+	return Activation{Kind: ActivationH24}
+}
+
+var (
+	weekdays = map[string]time.Weekday{
+		"SUN": time.Sunday, "MON": time.Monday, "TUE": time.Tuesday, "WED": time.Wednesday,
+		"THU": time.Thursday, "FRI": time.Friday, "SAT": time.Saturday,
+	}
+	hoursRange = regexp.MustCompile(`^(\d{4}|S[RS](?:[+-]\d+)?)-(\d{4}|S[RS](?:[+-]\d+)?)$`)
+)
+
+// ParseActivation parses a schedule such as "H24", "NOTAM", "HJ" (sunrise to sunset), "HN"
+// (sunset to sunrise), or a list of daily hours separated by semicolons, e.g.
+// "MON-FRI 0800-1700; SAT,SUN SR-30-SS+30". Times are UTC, and offsets are in minutes.
+func ParseActivation(s string) (Activation, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	switch s {
+	case "", "H24":
+		return Activation{Kind: ActivationH24}, nil
+	case "NOTAM":
+		return Activation{Kind: ActivationNOTAM}, nil
+	case "HJ":
+		return Activation{Kind: ActivationHours, Hours: []DailyHours{{Start: TimeOfDay{Event: Sunrise}, End: TimeOfDay{Event: Sunset}}}}, nil
+	case "HN":
+		return Activation{Kind: ActivationHours, Hours: []DailyHours{{Start: TimeOfDay{Event: Sunset}, End: TimeOfDay{Event: Sunrise}}}}, nil
+	}
+
+	a := Activation{Kind: ActivationHours}
+	for _, part := range strings.Split(s, ";") {
+		h, err := parseDailyHours(strings.Fields(part))
+		if err != nil {
+			return Activation{}, fmt.Errorf("invalid schedule %q: %w", s, err)
+		}
+		a.Hours = append(a.Hours, h)
+	}
+	return a, nil
+}
+
+// parseDailyHours parses the fields of e.g. "MON-FRI 0800-1700". All but the last field are days.
+func parseDailyHours(fields []string) (DailyHours, error) {
+	var h DailyHours
+	if len(fields) == 0 {
+		return h, fmt.Errorf("missing hours")
+	}
+
+	m := hoursRange.FindStringSubmatch(fields[len(fields)-1])
+	if m == nil {
+		return h, fmt.Errorf("bad hours %q", fields[len(fields)-1])
+	}
+	var err error
+	if h.Start, err = parseTimeOfDay(m[1]); err != nil {
+		return h, err
+	}
+	if h.End, err = parseTimeOfDay(m[2]); err != nil {
+		return h, err
+	}
+
+	for _, field := range fields[:len(fields)-1] {
+		for _, days := range strings.Split(field, ",") {
+			d, err := parseDays(days)
+			if err != nil {
+				return h, err
+			}
+			h.Days = append(h.Days, d...)
+		}
+	}
+	return h, nil
+}
+
+// parseDays parses a day, e.g. "MON", or a range of days, e.g. "MON-FRI" or "FRI-SUN".
+func parseDays(s string) ([]time.Weekday, error) {
+	if s == "" {
+		return nil, nil
+	}
+	ends := strings.SplitN(s, "-", 2)
+	first, ok := weekdays[ends[0]]
+	if !ok {
+		return nil, fmt.Errorf("bad day %q", ends[0])
+	}
+	if len(ends) == 1 {
+		return []time.Weekday{first}, nil
+	}
+	last, ok := weekdays[ends[1]]
+	if !ok {
+		return nil, fmt.Errorf("bad day %q", ends[1])
+	}
+	days := []time.Weekday{first}
+	for d := first; d != last; {
+		d = (d + 1) % 7
+		days = append(days, d)
+	}
+	return days, nil
+}
+
+func parseTimeOfDay(s string) (TimeOfDay, error) {
+	if strings.HasPrefix(s, "SR") || strings.HasPrefix(s, "SS") {
+		t := TimeOfDay{Event: SolarEvent(s[:2])}
+		if len(s) > 2 {
+			minutes, err := strconv.Atoi(s[2:])
+			if err != nil {
+				return t, fmt.Errorf("bad offset %q", s)
+			}
+			t.Offset = time.Duration(minutes) * time.Minute
+		}
+		return t, nil
+	}
+
+	hours, _ := strconv.Atoi(s[:2])
+	minutes, _ := strconv.Atoi(s[2:])
+	if hours > 24 || minutes > 59 || hours == 24 && minutes > 0 {
+		return TimeOfDay{}, fmt.Errorf("bad time %q", s)
+	}
+	return TimeOfDay{Offset: time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute}, nil
+}
+
+// DecodeSchedules decodes a file of activation schedules, keyed by feature or volume ID:
+//
+//	# Danger areas active during published hours, rather than by NOTAM.
+//	d201-aberporth: MON-FRI 0830-1700
+//	d201a-aberporth: HJ
+//
+// Each schedule is parsed by ParseActivation. yaixm only says whether a volume is activated by
+// NOTAM, so this is how published hours are attached to it; see SetSchedules.
+func DecodeSchedules(data []byte) (map[string]Activation, error) {
+	var raw map[string]string
+	if err := yaml.UnmarshalStrict(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal YAML: %w", err)
+	}
+	schedules := make(map[string]Activation, len(raw))
+	for id, s := range raw {
+		a, err := ParseActivation(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", id, err)
+		}
+		schedules[id] = a
+	}
+	return schedules, nil
+}
+
+// SetSchedules sets the Activation of the volumes of `features` from `schedules`, as decoded by
+// DecodeSchedules. A schedule keyed by a feature ID applies to each of its volumes, and one
+// keyed by a volume ID to that volume alone, overriding its feature's. The features are updated
+// in place. SetSchedules returns, in order, the IDs that matched no feature or volume, which
+// usually means the airspace has changed since the schedules were written.
+func SetSchedules(features []Feature, schedules map[string]Activation) []string {
+	used := make(map[string]bool, len(schedules))
+	for i := range features {
+		feat := &features[i]
+		featSchedule, featOK := schedules[feat.ID]
+		if featOK {
+			used[feat.ID] = true
+		}
+		for j := range feat.Geometry {
+			vol := &feat.Geometry[j]
+			if a, ok := schedules[vol.ID]; ok {
+				used[vol.ID] = true
+				vol.Activation = a
+			} else if featOK {
+				vol.Activation = featSchedule
+			}
+		}
+	}
+
+	var unused []string
+	for id := range schedules {
+		if !used[id] {
+			unused = append(unused, id)
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
+
+// sunriseSunset returns the times of sunrise and sunset on the UTC day `day` at point `p`,
+// using the sunrise equation (accurate to a minute or two). It returns false if the sun
+// does not rise or does not set that day.
+//
+// See https://en.wikipedia.org/wiki/Sunrise_equation
+func sunriseSunset(day time.Time, p orb.Point) (time.Time, time.Time, bool) {
+	const j2000 = 2451545.0
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	julianDate := float64(midnight.Unix())/86400 + 2440587.5
+
+	n := math.Ceil(julianDate - j2000 + 0.0008)
+	meanSolarTime := n - p.Lon()/360
+	anomaly := math.Mod(357.5291+0.98560028*meanSolarTime, 360)
+	m := toRadians(anomaly)
+	centre := 1.9148*math.Sin(m) + 0.02*math.Sin(2*m) + 0.0003*math.Sin(3*m)
+	lambda := toRadians(math.Mod(anomaly+centre+180+102.9372, 360))
+	transit := j2000 + meanSolarTime + 0.0053*math.Sin(m) - 0.0069*math.Sin(2*lambda)
+
+	sinDeclination := math.Sin(lambda) * math.Sin(toRadians(23.4397))
+	cosDeclination := math.Cos(math.Asin(sinDeclination))
+	lat := toRadians(p.Lat())
+	cosHourAngle := (math.Sin(toRadians(-0.833)) - math.Sin(lat)*sinDeclination) / (math.Cos(lat) * cosDeclination)
+	if cosHourAngle < -1 || cosHourAngle > 1 {
+		return time.Time{}, time.Time{}, false
+	}
+	hourAngle := toDegrees(math.Acos(cosHourAngle))
+
+	fromJulian := func(j float64) time.Time {
+		return time.Unix(0, int64((j-2440587.5)*86400*1e9)).UTC()
+	}
+	return fromJulian(transit - hourAngle/360), fromJulian(transit + hourAngle/360), true
+}
+
+// QueryAtTime returns every volume enclosing `point` that is active at time `t`.
+func (idx *Index) QueryAtTime(point orb.Point, t time.Time) []Volume {
+	return activeVolumes(idx.Query(point), t)
+}
+
+// EnclosingVolumesAtTime returns every volume enclosing `point` that is active at time `t`.
+// It tests every volume; see Index.QueryAtTime.
+func EnclosingVolumesAtTime(point orb.Point, t time.Time, features map[string]Feature) []Volume {
+	return sortByID(scanIndex(features).QueryAtTime(point, t))
+}
+
+func activeVolumes(volumes []Volume, t time.Time) []Volume {
+	var active []Volume
+	for _, v := range volumes {
+		if v.ActiveAt(t) {
+			active = append(active, v)
+		}
+	}
+	return active
+}
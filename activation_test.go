@@ -0,0 +1,264 @@
+package airspace
+
+import (
+	"testing"
+	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseActivation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Activation
+	}{
+		{"H24", Activation{Kind: ActivationH24}},
+		{"", Activation{Kind: ActivationH24}},
+		{"notam", Activation{Kind: ActivationNOTAM}},
+		{"HJ", Activation{Kind: ActivationHours, Hours: []DailyHours{{Start: TimeOfDay{Event: Sunrise}, End: TimeOfDay{Event: Sunset}}}}},
+		{"HN", Activation{Kind: ActivationHours, Hours: []DailyHours{{Start: TimeOfDay{Event: Sunset}, End: TimeOfDay{Event: Sunrise}}}}},
+		{"0800-1700", Activation{Kind: ActivationHours, Hours: []DailyHours{
+			{Start: TimeOfDay{Offset: 8 * time.Hour}, End: TimeOfDay{Offset: 17 * time.Hour}},
+		}}},
+		{"MON-FRI 0800-1730; SAT,SUN SR-30-SS+15", Activation{Kind: ActivationHours, Hours: []DailyHours{
+			{
+				Days:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+				Start: TimeOfDay{Offset: 8 * time.Hour},
+				End:   TimeOfDay{Offset: 17*time.Hour + 30*time.Minute},
+			},
+			{
+				Days:  []time.Weekday{time.Saturday, time.Sunday},
+				Start: TimeOfDay{Event: Sunrise, Offset: -30 * time.Minute},
+				End:   TimeOfDay{Event: Sunset, Offset: 15 * time.Minute},
+			},
+		}}},
+		{"FRI-MON 2200-0600", Activation{Kind: ActivationHours, Hours: []DailyHours{{
+			Days:  []time.Weekday{time.Friday, time.Saturday, time.Sunday, time.Monday},
+			Start: TimeOfDay{Offset: 22 * time.Hour},
+			End:   TimeOfDay{Offset: 6 * time.Hour},
+		}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			a, err := ParseActivation(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, a)
+		})
+	}
+}
+
+func TestParseActivationErrors(t *testing.T) {
+	for _, input := range []string{"0800", "MON 0800-2500", "XYZ 0800-1700", "MON-XYZ 0800-1700", "0800-1700;"} {
+		t.Run(input, func(t *testing.T) {
+			_, err := ParseActivation(input)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTimeOfDayString(t *testing.T) {
+	assert.Equal(t, "0830", TimeOfDay{Offset: 8*time.Hour + 30*time.Minute}.String())
+	assert.Equal(t, "SR", TimeOfDay{Event: Sunrise}.String())
+	assert.Equal(t, "SR-30", TimeOfDay{Event: Sunrise, Offset: -30 * time.Minute}.String())
+	assert.Equal(t, "SS+15", TimeOfDay{Event: Sunset, Offset: 15 * time.Minute}.String())
+}
+
+func TestSunriseSunset(t *testing.T) {
+	london := orb.Point{-0.1276, 51.5072}
+
+	// Published times for London: 04:43 and 21:21 BST on the summer solstice; 08:03 and 15:54
+	// GMT on the winter solstice.
+	rise, set, ok := sunriseSunset(time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), london)
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Date(2024, 6, 21, 3, 43, 0, 0, time.UTC), rise, 2*time.Minute)
+	assert.WithinDuration(t, time.Date(2024, 6, 21, 20, 21, 0, 0, time.UTC), set, 2*time.Minute)
+
+	rise, set, ok = sunriseSunset(time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC), london)
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Date(2024, 12, 21, 8, 3, 0, 0, time.UTC), rise, 2*time.Minute)
+	assert.WithinDuration(t, time.Date(2024, 12, 21, 15, 54, 0, 0, time.UTC), set, 2*time.Minute)
+
+	// No sunset at midsummer in Svalbard.
+	_, _, ok = sunriseSunset(time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), orb.Point{15.6, 78.2})
+	assert.False(t, ok)
+}
+
+func TestActiveAt(t *testing.T) {
+	london := Volume{Circle: Circle{Radius: 1000, Centre: orb.Point{-0.1276, 51.5072}}}
+	at := func(activation string, validity ...TimeWindow) Volume {
+		v := london
+		var err error
+		v.Activation, err = ParseActivation(activation)
+		require.NoError(t, err)
+		v.Validity = validity
+		return v
+	}
+	// A Wednesday.
+	day := time.Date(2024, 6, 19, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		vol      Volume
+		t        time.Time
+		expected bool
+	}{
+		{"H24", at("H24"), day, true},
+		{"NOTAM", at("NOTAM"), day, true},
+		{"Zero activation", Volume{}, day, true},
+		{"Within hours", at("MON-FRI 0800-1700"), day.Add(12 * time.Hour), true},
+		{"Before hours", at("MON-FRI 0800-1700"), day.Add(7 * time.Hour), false},
+		{"At end of hours", at("MON-FRI 0800-1700"), day.Add(17 * time.Hour), false},
+		{"Wrong day", at("SAT,SUN 0800-1700"), day.Add(12 * time.Hour), false},
+		{"Overnight, before midnight", at("WED 2200-0600"), day.Add(23 * time.Hour), true},
+		{"Overnight, after midnight", at("WED 2200-0600"), day.Add(29 * time.Hour), true},
+		{"Overnight, next night", at("WED 2200-0600"), day.Add(47 * time.Hour), false},
+		{"Daylight", at("HJ"), day.Add(12 * time.Hour), true},
+		{"Before sunrise", at("HJ"), day.Add(3 * time.Hour), false},
+		{"After sunrise", at("HJ"), day.Add(4 * time.Hour), true},
+		{"Night", at("HN"), day.Add(2 * time.Hour), true},
+		{"Sunset relative", at("SS-60-SS+60"), day.Add(20*time.Hour + 30*time.Minute), true},
+		{"Outside validity", at("H24", TimeWindow{End: day}), day.Add(time.Hour), false},
+		{"Within validity and hours", at("0800-1700", TimeWindow{Start: day}), day.Add(9 * time.Hour), true},
+		{"Within windows", Volume{Activation: Activation{Kind: ActivationWindows, Windows: []TimeWindow{{Start: day, End: day.Add(time.Hour)}}}}, day, true},
+		{"Outside windows", Volume{Activation: Activation{Kind: ActivationWindows, Windows: []TimeWindow{{Start: day, End: day.Add(time.Hour)}}}}, day.Add(time.Hour), false},
+		{"Outside NOTAM windows", Volume{Activation: Activation{Kind: ActivationNOTAM, Windows: []TimeWindow{{Start: day, End: day.Add(time.Hour)}}}}, day.Add(time.Hour), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.vol.ActiveAt(tt.t))
+		})
+	}
+}
+
+func TestDecodeRules(t *testing.T) {
+	features, err := Decode([]byte(`
+airspace:
+- name: FEATURE NOTAM
+  type: D
+  rules: [NOTAM]
+  geometry:
+  - upper: 2000 ft
+    lower: SFC
+    boundary:
+    - circle:
+        radius: 2 nm
+        centre: 520000N 0010000W
+- name: VOLUME NOTAM
+  type: D
+  geometry:
+  - seqno: 1
+    rules: [SI, NOTAM]
+    upper: 2000 ft
+    lower: SFC
+    boundary:
+    - circle:
+        radius: 2 nm
+        centre: 520000N 0010000W
+  - seqno: 2
+    upper: 2000 ft
+    lower: SFC
+    boundary:
+    - circle:
+        radius: 2 nm
+        centre: 520000N 0010000W
+`))
+	require.NoError(t, err)
+	require.Len(t, features, 2)
+	assert.Equal(t, ActivationNOTAM, features[0].Geometry[0].Activation.Kind)
+	assert.Equal(t, ActivationNOTAM, features[1].Geometry[0].Activation.Kind)
+	assert.Equal(t, ActivationH24, features[1].Geometry[1].Activation.Kind)
+}
+
+func TestQueryAtTime(t *testing.T) {
+	features, err := Decode([]byte(data))
+	require.NoError(t, err)
+	day := time.Date(2024, 6, 19, 0, 0, 0, 0, time.UTC)
+	for i := range features[0].Geometry {
+		features[0].Geometry[i].Activation, _ = ParseActivation("0800-1700")
+	}
+
+	point := orb.Point{-2.2, 57.4}
+	idx := NewIndex(features)
+	assert.Len(t, idx.QueryAtTime(point, day.Add(12*time.Hour)), 1)
+	assert.Empty(t, idx.QueryAtTime(point, day.Add(18*time.Hour)))
+
+	m := map[string]Feature{features[0].ID: features[0]}
+	assert.Len(t, EnclosingVolumesAtTime(point, day.Add(12*time.Hour), m), 1)
+	assert.Empty(t, EnclosingVolumesAtTime(point, day, m))
+}
+
+func TestSetSchedules(t *testing.T) {
+	features, err := Decode([]byte(`
+airspace:
+- name: ABERPORTH
+  id: d201-aberporth
+  type: D
+  rules: [NOTAM]
+  notes: Mon-Fri 0830-1700, or by NOTAM.
+  geometry:
+  - seqno: 1
+    upper: FL500
+    lower: SFC
+    boundary:
+    - circle:
+        radius: 10 nm
+        centre: 521000N 0043000W
+  - seqno: 2
+    id: d201a-aberporth
+    upper: FL500
+    lower: SFC
+    boundary:
+    - circle:
+        radius: 10 nm
+        centre: 521000N 0043000W
+`))
+	require.NoError(t, err)
+	schedules, err := DecodeSchedules([]byte(`
+d201-aberporth: MON-FRI 0830-1700
+d201a-aberporth: HJ
+d999-gone: H24
+`))
+	require.NoError(t, err)
+
+	unused := SetSchedules(features, schedules)
+	assert.Equal(t, []string{"d999-gone"}, unused)
+
+	vol := features[0].Geometry[0]
+	require.Equal(t, ActivationHours, vol.Activation.Kind)
+	assert.Equal(t, []DailyHours{{
+		Days:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		Start: TimeOfDay{Offset: 8*time.Hour + 30*time.Minute},
+		End:   TimeOfDay{Offset: 17 * time.Hour},
+	}}, vol.Activation.Hours)
+	wednesday := time.Date(2024, 6, 19, 0, 0, 0, 0, time.UTC)
+	assert.True(t, vol.ActiveAt(wednesday.Add(12*time.Hour)))
+	assert.False(t, vol.ActiveAt(wednesday.Add(18*time.Hour)))
+	assert.False(t, vol.ActiveAt(wednesday.AddDate(0, 0, 3).Add(12*time.Hour)))
+
+	// The volume's own schedule overrides its feature's.
+	vol = features[0].Geometry[1]
+	assert.True(t, vol.ActiveAt(wednesday.AddDate(0, 0, 3).Add(12*time.Hour)))
+	assert.False(t, vol.ActiveAt(wednesday.Add(time.Hour)))
+
+	idx := NewIndex(features)
+	assert.Len(t, idx.QueryAtTime(orb.Point{-4.5, 52.166}, wednesday.Add(12*time.Hour)), 2)
+	assert.Empty(t, idx.QueryAtTime(orb.Point{-4.5, 52.166}, wednesday.Add(time.Hour)))
+}
+
+func TestDecodeSchedulesErrors(t *testing.T) {
+	for _, s := range []string{
+		`d201-aberporth: MON-FRY 0830-1700`,
+		`d201-aberporth: [MON-FRI 0830-1700]`,
+		`not: valid: yaml`,
+	} {
+		_, err := DecodeSchedules([]byte(s))
+		assert.Error(t, err, s)
+	}
+	_, err := DecodeSchedules([]byte(`d201-aberporth: 2500-2600`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "d201-aberporth")
+}
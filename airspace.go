@@ -1,17 +1,19 @@
 package airspace
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"math"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/paulmach/orb/geo"
-	"github.com/paulmach/orb/project"
 
 	"github.com/paulmach/orb"
 	"github.com/paulmach/orb/planar"
@@ -27,10 +29,21 @@ import (
 var _ = planar.Length
 
 type Feature struct {
-	ID       string
-	Name     string
-	Type     string
-	Class    string
+	ID    string
+	Name  string
+	Type  string
+	Class string
+	// The published type of airspace whose Type has been taken from it, e.g. "MATZ" or "GLIDER".
+	LocalType string `json:",omitempty"`
+	// Who controls the airspace: CIVIL, MILITARY or JOINT.
+	ControlType string `json:",omitempty"`
+	// yaixm rules, such as NOTAM, SI (defined by statutory instrument), TRA or INTERMITTENT.
+	Rules []string `json:",omitempty"`
+	// The air traffic service to call, and its frequency in MHz (e.g. "120.775"), if known.
+	Callsign  string `json:",omitempty"`
+	Frequency string `json:",omitempty"`
+	// Notes published with the airspace.
+	Remarks  string `json:",omitempty"`
 	Geometry []Volume
 }
 
@@ -40,14 +53,44 @@ type Volume struct {
 	Type              string
 	Class             string
 	Sequence          int
-	Lower             float64
-	Upper             float64
+	Lower             Altitude
+	Upper             Altitude
 	ClearanceRequired bool
 	Danger            bool
+	// As for the Feature; Rules also include any that apply to this volume alone.
+	LocalType   string   `json:",omitempty"`
+	ControlType string   `json:",omitempty"`
+	Rules       []string `json:",omitempty"`
+	Callsign    string   `json:",omitempty"`
+	Frequency   string   `json:",omitempty"`
+	Remarks     string   `json:",omitempty"`
 	// The (horizontal) shape will be either a circle or a polygon.
 	// One of:
 	Circle  Circle
 	Polygon orb.Ring
+	// The boundary as published, before arcs were flattened into Polygon.
+	Boundary []Segment
+	// If not empty, the volume only exists during these windows, e.g. a RA(T) for an air display.
+	Validity []TimeWindow `json:",omitempty"`
+	// When, within its validity, the volume is active.
+	Activation Activation
+}
+
+// MarshalJSON encodes the volume with its limits both as Altitudes and, as LowerFeet and
+// UpperFeet, in feet AMSL under StandardConditions. Before limits were Altitudes, Lower and
+// Upper were plain numbers of feet, and clients of the /v4 API still rely on them.
+func (v Volume) MarshalJSON() ([]byte, error) {
+	type volume Volume // Without the MarshalJSON method.
+	// The caller's encoder escapes HTML, if it has been asked to.
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(struct {
+		volume
+		LowerFeet interface{}
+		UpperFeet interface{}
+	}{volume(v), jsonFeet(v.Lower), jsonFeet(v.Upper)})
+	return b.Bytes(), err
 }
 
 type Circle struct {
@@ -55,54 +98,30 @@ type Circle struct {
 	Centre orb.Point
 }
 
-var (
-	prohibitedAirspaceClasses = map[string]bool{
-		"A": true,  // Most airways; London/Manchester TMAs.
-		"B": true,  // Not used in UK
-		"C": true,  // Mostly above FL195 and some airways.
-		"D": true,  // Most aerodrome CTRs and CTAs. Some TMAs and lower levels of selected airways.
-		"E": true,  // Scottish airways. Technically permissible (ATC clearance not required for VFR flight), but pilots encouraged to contact ATC.
-		"F": false, // Not used in UK
-		"G": false, // ‘Open FIR’, ATC clearance not required, radio not required.
-	}
-
-	// Not all are strictly prohibited, some are "avoid unless ...."
-	prohibitedTypes = map[string]bool{
-		"ATZ":  true, // Aerodrome Traffic Zone
-		"AWY":  true, // Airway
-		"CTA":  true, // Control Area (usually on top of a CTR).
-		"CTR":  true, // Control Region
-		"MATZ": true, // Military ATZ. Technically permissible.
-		"P":    true, // Prohibited area
-		"R":    true, // Restricted area
-		"RAT":  true, // Temporary restricted area
-		"RMZ":  true, // Radio mandatory zone
-		"TMA":  true, // Terminal control area
-		"TRA":  true, // Temporary reserved area
-		"TMZ":  true, // Transponder mandatory zone
-	}
-
-	dangerTypes = map[string]bool{
-		"AIAA":    true,  // Areas of intense arial activity
-		"D":       true,  // Danger area
-		"D_OTHER": true,  // Dangerous activity, but not a Danger area
-		"DZ":      true,  // Drop Zone
-		"GLIDER":  true,  // Gliding operations
-		"GVS":     false, // Gas venting station
-		"HIRTA":   true,  // High intensity radio transmission area
-		"ILS":     false, // ILS feather
-		"LASER":   true,  // Laser site.
-		"NOATZ":   true,  // Non-ATZ airfield
-		"UL":      true,  // Ultra-light strip
-	}
-)
+// Segment is one part of a volume's boundary. Exactly one of its fields is set.
+type Segment struct {
+	Line   orb.LineString
+	Arc    *Arc
+	Circle *Circle
+}
+
+// Arc is a circular arc from From to To, around Centre. Radius is in metres.
+type Arc struct {
+	Centre    orb.Point
+	Radius    float64
+	Clockwise bool
+	From      orb.Point
+	To        orb.Point
+}
 
+// ClearanceRequired reports whether the feature needs a clearance under the default policy.
 func ClearanceRequired(f Feature) bool {
-	return prohibitedAirspaceClasses[f.Class] || prohibitedTypes[f.Type]
+	return defaultPolicy.ClearanceRequired(f.Class, f.Type)
 }
 
+// Danger reports whether the feature is dangerous under the default policy.
 func Danger(f Feature) bool {
-	return dangerTypes[f.Type]
+	return defaultPolicy.Danger(f.Type)
 }
 
 // This type is used to decode YAML data from https://raw.githubusercontent.com/ahsparrow/airspace/master/airspace.yaml (and equivalent).
@@ -133,10 +152,21 @@ type airspaceResponse struct {
 					To     string
 				}
 			}
+			Rules []string
 			Lower string
 			Upper string
 		}
+		Rules []string
+		Notes string
 	}
+	Service []service
+}
+
+// service is a yaixm air traffic service, with the IDs of the features and volumes it controls.
+type service struct {
+	Callsign  string
+	Frequency string
+	Controls  []string
 }
 
 type ratResponse struct {
@@ -161,18 +191,62 @@ type ratResponse struct {
 				To     string
 			}
 		}
+		Rules []string
 		Lower string
 		Upper string
 	}
 }
 
+// Release describes a release of the yaixm data, taken from the `release` header of the YAML.
+type Release struct {
+	AIRACDate     string `yaml:"airac_date" json:",omitempty"`
+	Timestamp     string `json:",omitempty"`
+	SchemaVersion int    `yaml:"schema_version" json:",omitempty"`
+	Note          string `json:",omitempty"`
+}
+
+// DecodeRelease returns the release header of YAML airspace data, or nil if it has none (as is
+// always the case for OpenAir data).
+func DecodeRelease(data []byte) (*Release, error) {
+	if isOpenAir(data) {
+		return nil, nil
+	}
+	var r struct {
+		Release *Release
+	}
+	if err := yaml.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal YAML: %w", err)
+	}
+	return r.Release, nil
+}
+
 func Decode(data []byte) ([]Feature, error) {
 	var a airspaceResponse
 	if err := yaml.Unmarshal(data, &a); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal YAML: %w", err)
 	}
-	features, err := normalise(&a)
-	return features, err
+	features, issues := normalise(&a)
+	if err := strictError(issues); err != nil {
+		return nil, err
+	}
+	return features, nil
+}
+
+// DecodeLenient decodes yaixm data like Decode, but instead of stopping at the first problem
+// it carries on, skipping anything it can't parse, and returns every problem it found. It
+// only returns an error if the data is not valid YAML. OpenAir data is also accepted, but is
+// decoded as strictly as by DecodeOpenAir. See also Validate.
+func DecodeLenient(data []byte) ([]Feature, []Issue, error) {
+	if isOpenAir(data) {
+		features, err := DecodeOpenAir(bytes.NewReader(data))
+		return features, nil, err
+	}
+	var a airspaceResponse
+	if err := yaml.Unmarshal(data, &a); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal YAML: %w", err)
+	}
+	features, issues := normalise(&a)
+	return features, issues, nil
 }
 
 // normalise converts the raw YAML airspace data into our internal Feature representation.
@@ -181,8 +255,19 @@ func Decode(data []byte) ([]Feature, error) {
 //  2. Generating IDs for features that don't have explicit IDs
 //  3. Converting each geometry volume with its boundaries (circles, lines, arcs)
 //  4. Classifying each feature as prohibited or danger
-func normalise(a *airspaceResponse) ([]Feature, error) {
-	var features []Feature
+//  5. Attaching the callsign and frequency of the service controlling each feature or volume
+func normalise(a *airspaceResponse) ([]Feature, []Issue) {
+	var (
+		features []Feature
+		issues   []Issue
+	)
+	services := make(map[string]service)
+	for _, s := range a.Service {
+		for _, id := range s.Controls {
+			services[id] = s
+		}
+	}
+
 	for i, f := range a.Airspace {
 		// Determine the actual airspace type
 		airspaceType := resolveAirspaceType(f.Type, f.LocalType)
@@ -191,17 +276,25 @@ func normalise(a *airspaceResponse) ([]Feature, error) {
 		featureID := resolveFeatureID(f.ID, f.Name, i)
 
 		feat := Feature{
-			ID:    featureID,
-			Name:  f.Name,
-			Type:  airspaceType,
-			Class: f.Class,
+			ID:          featureID,
+			Name:        f.Name,
+			Type:        airspaceType,
+			Class:       f.Class,
+			LocalType:   f.LocalType,
+			ControlType: f.ControlType,
+			Rules:       f.Rules,
+			Remarks:     strings.TrimSpace(f.Notes),
+		}
+		if s, ok := services[featureID]; ok {
+			feat.Callsign, feat.Frequency = s.Callsign, s.Frequency
 		}
 
 		// Process each geometry volume (a feature can have multiple volumes at different altitudes)
 		for _, g := range f.Geometry {
-			vol, err := processGeometry(g, feat)
-			if err != nil {
-				return nil, err
+			vol, volIssues := processGeometry(g, feat)
+			issues = append(issues, volIssues...)
+			if s, ok := services[vol.ID]; ok {
+				vol.Callsign, vol.Frequency = s.Callsign, s.Frequency
 			}
 			feat.Geometry = append(feat.Geometry, vol)
 		}
@@ -209,7 +302,7 @@ func normalise(a *airspaceResponse) ([]Feature, error) {
 		features = append(features, feat)
 	}
 
-	return features, nil
+	return features, issues
 }
 
 // resolveAirspaceType determines the actual type, using LocalType for "OTHER" and "D_OTHER" types.
@@ -251,9 +344,10 @@ func processGeometry(g struct {
 			To     string
 		}
 	}
+	Rules []string
 	Lower string
 	Upper string
-}, feat Feature) (Volume, error) {
+}, feat Feature) (Volume, []Issue) {
 
 	// Inherit ID, name, and class from parent feature if not specified
 	volID := g.ID
@@ -270,64 +364,141 @@ func processGeometry(g struct {
 	}
 
 	vol := Volume{
-		ID:                volID,
-		Name:              volName,
-		Type:              feat.Type,
-		Class:             volClass,
-		Sequence:          g.Seqno,
-		Lower:             decodeHeight(g.Lower),
-		Upper:             decodeHeight(g.Upper),
-		ClearanceRequired: ClearanceRequired(feat),
-		Danger:            Danger(feat),
+		ID:          volID,
+		Name:        volName,
+		Type:        feat.Type,
+		Class:       volClass,
+		Sequence:    g.Seqno,
+		LocalType:   feat.LocalType,
+		ControlType: feat.ControlType,
+		Rules:       append(append([]string(nil), feat.Rules...), g.Rules...),
+		Callsign:    feat.Callsign,
+		Frequency:   feat.Frequency,
+		Remarks:     feat.Remarks,
+	}
+	vol.Activation = rulesActivation(vol.Rules)
+	vol = defaultPolicy.Classify(vol)
+
+	var issues []Issue
+	fail := func(code, field, value string, err error) {
+		pe := &ParseError{FeatureID: feat.ID, Sequence: vol.Sequence, Field: field, Value: value, Err: err}
+		var inner *ParseError
+		if errors.As(err, &inner) {
+			pe.Err = inner.Err
+		}
+		issues = append(issues, Issue{
+			Severity:  SeverityError,
+			Code:      code,
+			FeatureID: feat.ID,
+			VolumeID:  vol.ID,
+			Sequence:  vol.Sequence,
+			Message:   pe.message(),
+			err:       pe,
+		})
+	}
+	distance := func(field, d string) float64 {
+		m, err := ParseDistance(d)
+		if err != nil {
+			fail(IssueBadDistance, field, d, err)
+		}
+		return m
 	}
 
-	// Process boundary definitions (can be circles, lines, or arcs)
-	// currentPos tracks position for connecting arcs to previous line segments
-	var currentPos orb.Point
+	var err error
+	if vol.Lower, err = parseHeight(g.Lower); err != nil {
+		fail(IssueBadHeight, "lower", g.Lower, err)
+	}
+	if vol.Upper, err = parseHeight(g.Upper); err != nil {
+		fail(IssueBadHeight, "upper", g.Upper, err)
+	}
 
+	// Process boundary definitions (can be circles, lines, or arcs)
+	bb := boundaryBuilder{vol: &vol}
 	for _, b := range g.Boundary {
 		// Circle boundary (mutually exclusive with polygon boundaries)
 		if b.Circle.Radius != "" {
-			var err error
-			vol.Circle.Radius = decodeDistance(b.Circle.Radius)
-			vol.Circle.Centre, err = parseLatLng(b.Circle.Centre)
+			centre, err := ParseLatLng(b.Circle.Centre)
 			if err != nil {
-				return Volume{}, fmt.Errorf("bad circle %v: %s", b, err)
+				fail(IssueBadCoordinate, "circle centre", b.Circle.Centre, err)
+			} else {
+				bb.addCircle(Circle{Radius: distance("circle radius", b.Circle.Radius), Centre: centre})
 			}
 		}
 
 		// Line segments (straight lines between points)
 		for i := range b.Line {
-			p, err := parseLatLng(b.Line[i])
+			p, err := ParseLatLng(b.Line[i])
 			if err != nil {
-				return Volume{}, fmt.Errorf("bad line %v: %s", b, err)
+				fail(IssueBadCoordinate, "line point", b.Line[i], err)
+				continue
 			}
-			vol.Polygon = append(vol.Polygon, p)
-			currentPos = p // Track position for next arc
+			bb.addPoint(p)
 		}
 
 		// Arc segments (curved sections between two points around a center)
 		if b.Arc.Radius != "" {
-			to, err := parseLatLng(b.Arc.To)
+			to, err := ParseLatLng(b.Arc.To)
 			if err != nil {
-				return Volume{}, fmt.Errorf("bad arc %v: %s", b, err)
+				fail(IssueBadCoordinate, "arc end point", b.Arc.To, err)
+				continue
 			}
-			radius := decodeDistance(b.Arc.Radius)
-			centre, _ := parseLatLng(b.Arc.Centre)
-
-			// Direction: clockwise (+1.0) or counter-clockwise (-1.0)
-			dir := +1.0
-			if b.Arc.Dir == "ccw" {
-				dir = -1.0
+			centre, err := ParseLatLng(b.Arc.Centre)
+			if err != nil {
+				fail(IssueBadCoordinate, "arc centre", b.Arc.Centre, err)
+				continue
 			}
-
-			// Convert arc to polygon approximation and append to boundary
-			arc := arcToPolygon(centre, radius, currentPos, to, dir)
-			vol.Polygon = append(vol.Polygon, arc...)
+			bb.addArc(centre, distance("arc radius", b.Arc.Radius), b.Arc.Dir != "ccw", to)
 		}
 	}
 
-	return vol, nil
+	return vol, issues
+}
+
+// boundaryBuilder builds up a volume's Boundary one segment at a time, flattening arcs into
+// its Polygon as it goes.
+type boundaryBuilder struct {
+	vol *Volume
+	// currentPos tracks position for connecting arcs to previous line segments
+	currentPos orb.Point
+}
+
+func (bb *boundaryBuilder) addCircle(c Circle) {
+	bb.vol.Circle = c
+	bb.vol.Boundary = append(bb.vol.Boundary, Segment{Circle: &c})
+}
+
+// addPoint adds a point to the line segment at the end of the boundary, starting a new line
+// segment if necessary.
+func (bb *boundaryBuilder) addPoint(p orb.Point) {
+	b := bb.vol.Boundary
+	if len(b) == 0 || b[len(b)-1].Line == nil {
+		bb.vol.Boundary = append(bb.vol.Boundary, Segment{Line: orb.LineString{}})
+	}
+	last := &bb.vol.Boundary[len(bb.vol.Boundary)-1]
+	last.Line = append(last.Line, p)
+	bb.vol.Polygon = append(bb.vol.Polygon, p)
+	bb.currentPos = p
+}
+
+// addArc adds an arc from the current position to `to`.
+func (bb *boundaryBuilder) addArc(centre orb.Point, radius float64, clockwise bool, to orb.Point) {
+	// Direction: clockwise (+1.0) or counter-clockwise (-1.0)
+	dir := +1.0
+	if !clockwise {
+		dir = -1.0
+	}
+
+	// Convert arc to polygon approximation and append to boundary
+	arc := arcToPolygon(centre, radius, bb.currentPos, to, dir)
+	bb.vol.Polygon = append(bb.vol.Polygon, arc...)
+	bb.vol.Boundary = append(bb.vol.Boundary, Segment{Arc: &Arc{
+		Centre:    centre,
+		Radius:    radius,
+		Clockwise: clockwise,
+		From:      bb.currentPos,
+		To:        to,
+	}})
+	bb.currentPos = to
 }
 
 // arcToPolygon converts an arc boundary into a polygon approximation.
@@ -343,6 +514,11 @@ func processGeometry(g struct {
 //
 // Returns a LineString of points approximating the arc, including the final point.
 func arcToPolygon(centre orb.Point, radius float64, initialPoint orb.Point, to orb.Point, dir float64) orb.LineString {
+	return arcToPolygonStep(centre, radius, initialPoint, to, dir, circleStep)
+}
+
+// arcToPolygonStep is arcToPolygon with a vertex every `step` degrees.
+func arcToPolygonStep(centre orb.Point, radius float64, initialPoint orb.Point, to orb.Point, dir float64, step float64) orb.LineString {
 	// Calculate bearings from centre to start and end points
 	initialAngleDeg := geo.Bearing(centre, initialPoint)
 	finalAngleDeg := geo.Bearing(centre, to)
@@ -362,9 +538,9 @@ func arcToPolygon(centre orb.Point, radius float64, initialPoint orb.Point, to o
 		}
 	}
 
-	// Generate points along the arc every 10 degrees
+	// Generate points along the arc every `step` degrees
 	var poly orb.LineString
-	for a := initialAngleDeg; dir*a < dir*finalAngleDeg; a += dir * 10 {
+	for a := initialAngleDeg; dir*a < dir*finalAngleDeg; a += dir * step {
 		point := destinationPoint(centre, a, radius)
 		poly = append(poly, point)
 	}
@@ -384,7 +560,8 @@ func toDegrees(angle float64) float64 {
 }
 
 // destinationPoint calculates a destination point given a start point, bearing, and distance.
-// This uses the haversine formula for great circle calculations on a sphere.
+// Published radii are distances on the WGS84 ellipsoid, so it always uses WGS84, whichever
+// model is used to query the volumes.
 //
 // Parameters:
 //   - start: The starting point (lat/lon)
@@ -395,118 +572,175 @@ func toDegrees(angle float64) float64 {
 //
 // This is used to generate points along arcs at specific angles from the arc's center.
 func destinationPoint(start orb.Point, bearing float64, distance float64) orb.Point {
-	// Convert distance to angular distance (radians) by dividing by Earth's radius
-	angularDistance := distance / orb.EarthRadius
-
-	// Convert all angles to radians for trigonometric calculations
-	bearingRadians := toRadians(bearing)
-	lat1 := toRadians(start.Lat())
-	lon1 := toRadians(start.Lon())
-
-	// Calculate destination latitude using spherical trigonometry
-	// Formula: lat2 = asin(sin(lat1)*cos(d) + cos(lat1)*sin(d)*cos(bearing))
-	sinLat2 := math.Sin(lat1)*math.Cos(angularDistance) +
-		math.Cos(lat1)*math.Sin(angularDistance)*math.Cos(bearingRadians)
-	lat2 := math.Asin(sinLat2)
-
-	// Calculate destination longitude
-	// Formula: lon2 = lon1 + atan2(sin(bearing)*sin(d)*cos(lat1), cos(d) - sin(lat1)*sin(lat2))
-	x := math.Cos(angularDistance) - math.Sin(lat1)*sinLat2
-	y := math.Sin(bearingRadians) * math.Sin(angularDistance) * math.Cos(lat1)
-	lon2 := lon1 + math.Atan2(y, x)
-
-	// Convert back to degrees and return as Point{lon, lat}
-	return orb.Point{toDegrees(lon2), toDegrees(lat2)}
+	return WGS84.Destination(start, bearing, distance)
 }
 
-// parseLatLng converts airspace coordinate strings to WGS84 lat/lon points.
-// Expected format: "DDMMSSN DDDMMSSX" where:
-//   - DD/DDD = degrees (2 digits for lat, 3 for lon)
-//   - MM = minutes (2 digits)
-//   - SS = seconds (2 digits)
-//   - N/S = hemisphere for latitude
-//   - E/W = hemisphere for longitude
-// Example: "502257N 0033739W" = 50°22'57"N 003°37'39"W
-func parseLatLng(str string) (orb.Point, error) {
-	const expectedFormat = "502257N 0033739W"
-	formatError := fmt.Errorf("bad point: %#q, must be in format %q (degrees,minutes,seconds)", str, expectedFormat)
+// ParseError reports a value in the airspace data that could not be parsed. Use errors.As
+// to find it in the errors returned by Decode and its relatives.
+type ParseError struct {
+	FeatureID string
+	Sequence  int    // The volume's seqno, if it has one.
+	Field     string // What was being parsed, e.g. "upper", "lower" or "arc radius".
+	Value     string
+	Err       error
+}
 
-	// Validate length and space separator
-	if len(str) != 16 || str[7] != ' ' {
-		return orb.Point{}, formatError
+func (e *ParseError) Error() string {
+	switch {
+	case e.FeatureID == "":
+		return e.message()
+	case e.Sequence != 0:
+		return fmt.Sprintf("feature %q volume %d: %s", e.FeatureID, e.Sequence, e.message())
+	default:
+		return fmt.Sprintf("feature %q: %s", e.FeatureID, e.message())
 	}
+}
 
-	// Parse latitude (DDMMSSN)
-	latDeg, err1 := strconv.ParseUint(str[0:2], 10, 64)
-	latMin, err2 := strconv.ParseUint(str[2:4], 10, 64)
-	latSec, err3 := strconv.ParseUint(str[4:6], 10, 64)
-	if err1 != nil || err2 != nil || err3 != nil {
-		return orb.Point{}, formatError
-	}
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
 
-	// Convert to decimal degrees (60 minutes/degree, 3600 seconds/degree)
-	lat := float64(latDeg) + float64(latMin)/60.0 + float64(latSec)/3600.0
+// message describes the error without saying where it is.
+func (e *ParseError) message() string {
+	return fmt.Sprintf("invalid %s %q: %s", e.Field, e.Value, e.Err)
+}
 
-	// Apply hemisphere
-	hemisphereNS := str[6]
-	if hemisphereNS == 'S' {
-		lat = -lat
-	} else if hemisphereNS != 'N' {
-		return orb.Point{}, formatError
+// numError returns the reason a number could not be parsed, without repeating the input.
+func numError(err error) error {
+	var ne *strconv.NumError
+	if errors.As(err, &ne) {
+		return ne.Err
 	}
+	return err
+}
 
-	// Parse longitude (DDDMMSSX)
-	lonDeg, err1 := strconv.ParseUint(str[8:11], 10, 64)
-	lonMin, err2 := strconv.ParseUint(str[11:13], 10, 64)
-	lonSec, err3 := strconv.ParseUint(str[13:15], 10, 64)
-	if err1 != nil || err2 != nil || err3 != nil {
-		return orb.Point{}, formatError
+// ParseLatLng converts airspace coordinate strings to WGS84 lat/lon points. Latitude comes
+// first, separated from longitude by a space or comma. Each may be:
+//   - degrees, minutes and seconds: "502257N 0033739W" = 50°22'57"N 003°37'39"W, with 2
+//     digits of degrees for latitude and 3 for longitude. Seconds may have a fraction, e.g.
+//     "502257.5N 0033739.25W".
+//   - decimal degrees with a hemisphere, e.g. "50.3825N 3.6275W".
+//   - signed decimal degrees, e.g. "50.3825 -3.6275".
+//
+// Errors are of type *ParseError.
+func ParseLatLng(str string) (orb.Point, error) {
+	fields := strings.FieldsFunc(str, func(r rune) bool { return r == ' ' || r == ',' })
+	if len(fields) != 2 {
+		return orb.Point{}, &ParseError{Field: "point", Value: str, Err: errors.New(`must be like "502257N 0033739W"`)}
 	}
 
-	// Convert to decimal degrees
-	lon := float64(lonDeg) + float64(lonMin)/60.0 + float64(lonSec)/3600.0
-
-	// Apply hemisphere
-	hemisphereEW := str[15]
-	if hemisphereEW == 'W' {
-		lon = -lon
-	} else if hemisphereEW != 'E' {
-		return orb.Point{}, formatError
+	lat, err := parseAngle(fields[0], 2, 'N', 'S', 90)
+	if err != nil {
+		return orb.Point{}, &ParseError{Field: "latitude", Value: fields[0], Err: err}
+	}
+	lon, err := parseAngle(fields[1], 3, 'E', 'W', 180)
+	if err != nil {
+		return orb.Point{}, &ParseError{Field: "longitude", Value: fields[1], Err: err}
 	}
 
 	// Note: orb.Point is {lon, lat} - longitude comes first!
 	return orb.Point{lon, lat}, nil
 }
 
-func decodeHeight(h string) float64 {
-	h = strings.ToUpper(strings.TrimSpace(h))
-	if h == "" || h == "SFC" {
-		return 0
+// parseAngle parses one half of a coordinate, in any of the forms accepted by ParseLatLng.
+// degreeDigits is the number of digits of degrees in the DMS form.
+func parseAngle(s string, degreeDigits int, positive, negative byte, max float64) (float64, error) {
+	sign := 1.0
+	switch s[len(s)-1] {
+	case positive:
+		s = s[:len(s)-1]
+	case negative:
+		s, sign = s[:len(s)-1], -1
+	default:
+		// Signed decimal degrees. ParseFloat would also accept NaN, Inf, exponents and hex.
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || !isDecimal(strings.TrimLeft(s, "+-")) || math.Abs(f) > max {
+			return 0, errors.New("bad number or hemisphere")
+		}
+		return f, nil
 	}
 
-	if strings.HasPrefix(h, "FL") {
-		// Flight level.
-		f, err := strconv.ParseFloat(h[2:], 64)
-		if err != nil {
-			log.Printf("Could not parse flight levele %#q: %s\n", h, err)
+	for _, c := range s {
+		if (c < '0' || c > '9') && c != '.' {
+			return 0, fmt.Errorf("unexpected %q", c)
 		}
-		return f * 100 // Standard pressure and so on.
+	}
+	whole := s
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole = s[:i]
 	}
 
-	h = strings.TrimSpace(strings.TrimSuffix(h, "FT"))
-	f, err := strconv.ParseFloat(h, 64)
-	if err != nil {
-		log.Printf("Could not parse height %#q: %s\n", h, err)
+	var angle float64
+	switch {
+	case len(whole) == degreeDigits+4:
+		// Degrees, minutes and (possibly fractional) seconds.
+		deg, _ := strconv.Atoi(s[:degreeDigits])
+		min, _ := strconv.Atoi(s[degreeDigits : degreeDigits+2])
+		sec, err := strconv.ParseFloat(s[degreeDigits+2:], 64)
+		if err != nil || min >= 60 || sec >= 60 {
+			return 0, errors.New("bad minutes or seconds")
+		}
+		// Convert to decimal degrees (60 minutes/degree, 3600 seconds/degree)
+		angle = float64(deg) + float64(min)/60.0 + sec/3600.0
+	case len(whole) >= 1 && len(whole) <= degreeDigits:
+		// Decimal degrees.
+		var err error
+		if angle, err = strconv.ParseFloat(s, 64); err != nil {
+			return 0, errors.New("bad number")
+		}
+	default:
+		return 0, fmt.Errorf("must have %d digits of degrees, then minutes and seconds", degreeDigits)
 	}
-	return f
+
+	if angle > max {
+		return 0, fmt.Errorf("more than %v degrees", max)
+	}
+	return sign * angle, nil
 }
 
-func decodeDistance(d string) float64 {
-	f, err := strconv.ParseFloat(strings.TrimSuffix(d, " nm"), 64)
+// isDecimal reports whether `s` is an unsigned decimal number, such as "12" or "3.25".
+func isDecimal(s string) bool {
+	digits, points := 0, 0
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			digits++
+		case c == '.':
+			points++
+		default:
+			return false
+		}
+	}
+	return digits > 0 && points <= 1
+}
+
+var distanceUnits = map[string]float64{
+	"":   1852, // Nautical miles, if there are no units.
+	"nm": 1852,
+	"km": 1000,
+	"m":  1,
+}
+
+// ParseDistance parses a distance in nautical miles, kilometres or metres, e.g. "2.5 nm",
+// "5km" or "500 m", returning it in metres. A number without units is in nautical miles.
+// Negative and non-finite distances are rejected. Errors are of type *ParseError.
+func ParseDistance(d string) (float64, error) {
+	s := strings.ToLower(strings.TrimSpace(d))
+	number := strings.TrimRightFunc(s, unicode.IsLetter)
+	metres, ok := distanceUnits[strings.TrimSpace(s[len(number):])]
+	if !ok {
+		return 0, &ParseError{Field: "distance", Value: d, Err: fmt.Errorf("unknown units %q", s[len(number):])}
+	}
+
+	number = strings.TrimSpace(number)
+	f, err := strconv.ParseFloat(number, 64)
 	if err != nil {
-		log.Printf("Invalid distance %#q: %s\n", d, err)
+		return 0, &ParseError{Field: "distance", Value: d, Err: numError(err)}
+	}
+	if !isDecimal(strings.TrimPrefix(number, "+")) {
+		return 0, &ParseError{Field: "distance", Value: d, Err: errors.New("must be a decimal number, not negative")}
 	}
-	return nautMilesToMeters(f)
+	return f * metres, nil
 }
 
 func nautMilesToMeters(nm float64) float64 {
@@ -526,6 +760,7 @@ func metersToDegreesOfLng(m float64) float64 {
 	return m / 1852 / degToNautMileX
 }
 
+// Load fetches and decodes airspace from `url`, which may be in either the YAML or OpenAir format.
 func Load(url string) ([]Feature, error) {
 	resp, err := http.Get(url)
 	if err != nil {
@@ -536,9 +771,10 @@ func Load(url string) ([]Feature, error) {
 	if err != nil {
 		return nil, err
 	}
-	return Decode(b)
+	return DecodeAny(b)
 }
 
+// LoadFile reads and decodes airspace from a file in either the YAML or OpenAir format.
 func LoadFile(fileName string) ([]Feature, error) {
 	file, err := os.Open(fileName)
 	if err != nil {
@@ -549,30 +785,48 @@ func LoadFile(fileName string) ([]Feature, error) {
 	if err != nil {
 		return nil, err
 	}
+	return DecodeAny(b)
+}
+
+// DecodeAny decodes airspace in either format: OpenAir if its first record is an AC record, and
+// YAML otherwise.
+func DecodeAny(b []byte) ([]Feature, error) {
+	if isOpenAir(b) {
+		return DecodeOpenAir(bytes.NewReader(b))
+	}
 	return Decode(b)
 }
 
-func EnclosingVolumes(point orb.Point, features map[string]Feature) []Volume {
-	enclosingVolumes := make([]Volume, 0)
-	for _, f := range features {
-		for _, v := range f.Geometry {
-			if isEnclosedBy(point, v) {
-				enclosingVolumes = append(enclosingVolumes, v)
-			}
+func isOpenAir(b []byte) bool {
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "*") {
+			continue
 		}
+		return len(line) > 3 && strings.EqualFold(line[:3], "AC ")
 	}
+	return false
+}
 
-	return enclosingVolumes
+// EnclosingVolumes returns every volume enclosing `point`, in order of ID. It tests every
+// volume, so callers making more than one query should build an Index once with NewIndex and
+// use Index.Query instead.
+func EnclosingVolumes(point orb.Point, features map[string]Feature) []Volume {
+	return sortByID(scanIndex(features).Query(point))
 }
 
-func isEnclosedBy(p orb.Point, vol Volume) bool {
+// isEnclosedBy reports whether `p` is inside the volume's horizontal shape, using `model` to
+// measure distances from the centres of circles and arcs.
+func isEnclosedBy(p orb.Point, vol Volume, model EarthModel) bool {
 	if vol.Circle.Radius != 0 {
-		projectedCentre := project.Point(vol.Circle.Centre, project.WGS84.ToMercator)
-		projectedPoint := project.Point(p, project.WGS84.ToMercator)
-		if planar.Distance(projectedPoint, projectedCentre) <= vol.Circle.Radius {
+		if vol.Circle.Contains(p, model) {
 			return true
 		}
 	}
+	if hasArcs(vol.Boundary) {
+		// Use the true arcs rather than the flattened Polygon.
+		return boundaryContains(vol.Boundary, p, model)
+	}
 	if len(vol.Polygon) > 0 {
 		if planar.RingContains(vol.Polygon, p) {
 			return true
@@ -582,6 +836,49 @@ func isEnclosedBy(p orb.Point, vol Volume) bool {
 	return false
 }
 
+// DistanceToBoundary returns the distance, in metres, from `p` to the nearest point on the
+// boundary of the volume's horizontal shape, whether `p` is inside the volume or not. Distances
+// are measured on the WGS84 ellipsoid; Index.Nearest uses the index's model.
+func DistanceToBoundary(p orb.Point, vol Volume) float64 {
+	_, d := nearestBoundaryPoint(p, vol, WGS84)
+	return d
+}
+
+// nearestBoundaryPoint returns the nearest point on the volume's boundary to `p`, and the
+// distance to it in metres. Polygon edges are measured on a local plane centred on `p`, which
+// is accurate enough over the size of an airspace volume.
+func nearestBoundaryPoint(p orb.Point, vol Volume, model EarthModel) (orb.Point, float64) {
+	nearest := orb.Point{}
+	best := math.Inf(+1)
+
+	if vol.Circle.Radius != 0 {
+		d := model.Distance(p, vol.Circle.Centre)
+		best = math.Abs(d - vol.Circle.Radius)
+		nearest = model.Destination(vol.Circle.Centre, geo.Bearing(vol.Circle.Centre, p), vol.Circle.Radius)
+	}
+
+	scaleX, scaleY := model.localScale(p)
+	toLocal := func(q orb.Point) orb.Point {
+		return orb.Point{(q.Lon() - p.Lon()) * scaleX, (q.Lat() - p.Lat()) * scaleY}
+	}
+	for i := 0; i < len(vol.Polygon); i++ {
+		a, b := toLocal(vol.Polygon[i]), toLocal(vol.Polygon[(i+1)%len(vol.Polygon)])
+
+		// Project the origin (i.e. `p`) onto the segment a-b.
+		t := 0.0
+		if l2 := planar.DistanceSquared(a, b); l2 > 0 {
+			t = math.Max(0, math.Min(1, -(a[0]*(b[0]-a[0])+a[1]*(b[1]-a[1]))/l2))
+		}
+		q := orb.Point{a[0] + t*(b[0]-a[0]), a[1] + t*(b[1]-a[1])}
+		if d := math.Hypot(q[0], q[1]); d < best {
+			best = d
+			nearest = orb.Point{p.Lon() + q[0]/scaleX, p.Lat() + q[1]/scaleY}
+		}
+	}
+
+	return nearest, best
+}
+
 // https://developers.google.com/maps/documentation/javascript/overlays
 // https://www.w3.org/Graphics/SVG/IG/resources/svgprimer.html#scale
 // https://www.doc-developpement-durable.org/file/Projets-informatiques/cours-&-manuels-informatiques/htm-html-xml-ccs/Building%20Web%20Applications%20with%20SVG.pdf
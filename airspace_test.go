@@ -1,6 +1,8 @@
 package airspace
 
 import (
+	"errors"
+	"strconv"
 	"testing"
 
 	"github.com/paulmach/orb"
@@ -78,13 +80,73 @@ func TestDecode(t *testing.T) {
 	assert.Equal(t, "aberdeen-cta", features[0].ID)
 	assert.Equal(t, "D", features[0].Class)
 	assert.Equal(t, 3, len(features[0].Geometry))
-	assert.Equal(t, 11500.0, features[0].Geometry[0].Upper)
-	assert.Equal(t, 1500.0, features[0].Geometry[0].Lower)
+	assert.Equal(t, Altitude{Value: 11500, Unit: Feet, Reference: RefFL}, features[0].Geometry[0].Upper)
+	assert.Equal(t, Altitude{Value: 1500, Unit: Feet, Reference: RefAMSL}, features[0].Geometry[0].Lower)
 	assert.Equal(t, 3, len(features[0].Geometry))
 	assert.Equal(t, Circle{}, features[0].Geometry[0].Circle)
 	assert.Equal(t, 19, len(features[0].Geometry[0].Polygon))
 }
 
+func TestDecodeAttributes(t *testing.T) {
+	features, err := Decode([]byte(`
+airspace:
+- name: SHAWBURY
+  id: shawbury-matz
+  type: OTHER
+  localtype: MATZ
+  controltype: MILITARY
+  rules: [INTERMITTENT]
+  notes: |
+    Mon-Fri, or by NOTAM.
+  geometry:
+  - seqno: 1
+    id: shawbury-matz-stub
+    upper: 3000 ft
+    lower: 1000 ft
+    rules: [SI]
+    boundary:
+    - circle:
+        radius: 5 nm
+        centre: 524734N 0024005W
+  - seqno: 2
+    upper: 3000 ft
+    lower: SFC
+    boundary:
+    - circle:
+        radius: 2 nm
+        centre: 524734N 0024005W
+service:
+- callsign: SHAWBURY ZONE
+  frequency: 120.775
+  controls: [shawbury-matz]
+- callsign: SHAWBURY APPROACH
+  frequency: 124.150
+  controls: [shawbury-matz-stub]
+`))
+	require.NoError(t, err)
+	require.Len(t, features, 1)
+
+	f := features[0]
+	assert.Equal(t, "MATZ", f.Type)
+	assert.Equal(t, "MATZ", f.LocalType)
+	assert.Equal(t, "MILITARY", f.ControlType)
+	assert.Equal(t, []string{"INTERMITTENT"}, f.Rules)
+	assert.Equal(t, "Mon-Fri, or by NOTAM.", f.Remarks)
+	assert.Equal(t, "SHAWBURY ZONE", f.Callsign)
+	assert.Equal(t, "120.775", f.Frequency)
+
+	require.Len(t, f.Geometry, 2)
+	stub, zone := f.Geometry[0], f.Geometry[1]
+	assert.Equal(t, []string{"INTERMITTENT", "SI"}, stub.Rules)
+	assert.Equal(t, "SHAWBURY APPROACH", stub.Callsign)
+	assert.Equal(t, "124.150", stub.Frequency)
+	assert.Equal(t, []string{"INTERMITTENT"}, zone.Rules)
+	assert.Equal(t, "MILITARY", zone.ControlType)
+	assert.Equal(t, "SHAWBURY ZONE", zone.Callsign)
+	assert.Equal(t, "120.775", zone.Frequency)
+	assert.Equal(t, "Mon-Fri, or by NOTAM.", zone.Remarks)
+}
+
 func TestDecodeInvalidYAML(t *testing.T) {
 	// Test that invalid YAML is properly rejected
 	invalidYAML := []byte(`this is not: valid: yaml: {{{`)
@@ -281,11 +343,50 @@ func TestParseLatLng(t *testing.T) {
 			wantLon: -(1.0 + 58.0/60.0 + 35.0/3600.0),
 			wantErr: false,
 		},
+		{
+			name:    "Decimal seconds",
+			input:   "502257.5N 0033739.25W",
+			wantLat: 50.0 + 22.0/60.0 + 57.5/3600.0,
+			wantLon: -(3.0 + 37.0/60.0 + 39.25/3600.0),
+		},
+		{
+			name:    "Decimal degrees with hemisphere",
+			input:   "50.3825N 3.6275W",
+			wantLat: 50.3825,
+			wantLon: -3.6275,
+		},
+		{
+			name:    "Signed decimal degrees",
+			input:   "-33.865 151.2094",
+			wantLat: -33.865,
+			wantLon: 151.2094,
+		},
+		{
+			name:    "Comma separated",
+			input:   "51.5,-0.1275",
+			wantLat: 51.5,
+			wantLon: -0.1275,
+		},
 		{
 			name:    "Invalid length",
 			input:   "5020N 00330W",
 			wantErr: true,
 		},
+		{
+			name:    "Minutes out of range",
+			input:   "506057N 0033739W",
+			wantErr: true,
+		},
+		{
+			name:    "Latitude out of range",
+			input:   "91.5 0.0",
+			wantErr: true,
+		},
+		{
+			name:    "Too many parts",
+			input:   "502257N 0033739W 0033739W",
+			wantErr: true,
+		},
 		{
 			name:    "Missing space separator",
 			input:   "502257N00033739W",
@@ -301,11 +402,16 @@ func TestParseLatLng(t *testing.T) {
 			input:   "502257N 0033739Z",
 			wantErr: true,
 		},
+		{name: "NaN", input: "nan,nan", wantErr: true},
+		{name: "NaN longitude", input: "52,NaN", wantErr: true},
+		{name: "Infinity", input: "52,-Inf", wantErr: true},
+		{name: "Exponent", input: "5.2e1,-1", wantErr: true},
+		{name: "Hex float", input: "0x1p5,-1", wantErr: true},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseLatLng(tt.input)
+			got, err := ParseLatLng(tt.input)
 			if tt.wantErr {
 				assert.Error(t, err)
 				return
@@ -323,23 +429,27 @@ func TestDecodeHeight(t *testing.T) {
 	tests := []struct {
 		name  string
 		input string
-		want  float64
+		want  Altitude
 	}{
-		{"Surface", "SFC", 0},
-		{"Surface lowercase", "sfc", 0},
-		{"Empty string", "", 0},
-		{"Flight level 115", "FL115", 11500},
-		{"Flight level lowercase", "fl85", 8500},
-		{"Feet with suffix", "1500 ft", 1500},
-		{"Feet with uppercase suffix", "1500 FT", 1500},
-		{"Feet without suffix", "3000", 3000},
-		{"High flight level", "FL195", 19500},
-		{"Low altitude", "500 ft", 500},
+		{"Surface", "SFC", Altitude{Unit: Feet, Reference: RefSFC}},
+		{"Surface lowercase", "sfc", Altitude{Unit: Feet, Reference: RefSFC}},
+		{"Empty string", "", Altitude{Unit: Feet, Reference: RefSFC}},
+		{"Unlimited", "UNL", Altitude{Unit: Feet, Reference: RefUNL}},
+		{"Flight level 115", "FL115", Altitude{11500, Feet, RefFL}},
+		{"Flight level lowercase", "fl85", Altitude{8500, Feet, RefFL}},
+		{"Feet with suffix", "1500 ft", Altitude{1500, Feet, RefAMSL}},
+		{"Feet with uppercase suffix", "1500 FT", Altitude{1500, Feet, RefAMSL}},
+		{"Feet without suffix", "3000", Altitude{3000, Feet, RefAMSL}},
+		{"High flight level", "FL195", Altitude{19500, Feet, RefFL}},
+		{"Low altitude", "500 ft", Altitude{500, Feet, RefAMSL}},
+		{"Above surface", "2000 ft SFC", Altitude{2000, Feet, RefAGL}},
+		{"Metres", "600 m", Altitude{600, Metres, RefAMSL}},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := decodeHeight(tt.input)
+			got, err := parseHeight(tt.input)
+			require.NoError(t, err)
 			assert.Equal(t, tt.want, got)
 		})
 	}
@@ -356,14 +466,76 @@ func TestDecodeDistance(t *testing.T) {
 		{"5 nautical miles", "5 nm", 9260},    // 5 * 1852
 		{"1 nautical mile", "1 nm", 1852},
 		{"Fractional nm", "0.5 nm", 926},
+		{"No space", "2.5nm", 4630},
+		{"Upper case", "2 NM", 3704},
+		{"No units", "2", 3704},
+		{"Kilometres", "5 km", 5000},
+		{"Metres", "500m", 500},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := decodeDistance(tt.input)
+			got, err := ParseDistance(tt.input)
+			require.NoError(t, err)
 			assert.InDelta(t, tt.want, got, 0.1)
 		})
 	}
+
+	for _, input := range []string{"", "5 miles", "two nm", "nm", "NaN nm", "-5 nm", "Inf nm", "+Inf", "1e3 m", "0x10 km"} {
+		_, err := ParseDistance(input)
+		var pe *ParseError
+		assert.True(t, errors.As(err, &pe), input)
+	}
+}
+
+// TestParseError verifies that bad heights and distances fail the decode, saying where they are
+func TestParseError(t *testing.T) {
+	volume := func(upper, radius string) string {
+		return `
+airspace:
+- name: TEST
+  id: test
+  type: D
+  geometry:
+  - seqno: 2
+    upper: ` + upper + `
+    lower: SFC
+    boundary:
+    - circle:
+        radius: ` + radius + `
+        centre: 520000N 0010000W
+`
+	}
+
+	tests := []struct {
+		name     string
+		data     string
+		expected ParseError
+		err      string
+	}{
+		{"Bad flight level", volume("FL6O", "2 nm"), ParseError{FeatureID: "test", Sequence: 2, Field: "upper", Value: "FL6O", Err: strconv.ErrSyntax},
+			`feature "test" volume 2: invalid upper "FL6O": invalid syntax`},
+		{"Bad height", volume("2OOO ft", "2 nm"), ParseError{FeatureID: "test", Sequence: 2, Field: "upper", Value: "2OOO ft", Err: strconv.ErrSyntax},
+			`feature "test" volume 2: invalid upper "2OOO ft": invalid syntax`},
+		{"Bad radius", volume("FL65", "two nm"), ParseError{FeatureID: "test", Sequence: 2, Field: "circle radius", Value: "two nm", Err: strconv.ErrSyntax},
+			`feature "test" volume 2: invalid circle radius "two nm": invalid syntax`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode([]byte(tt.data))
+			require.Error(t, err)
+			assert.EqualError(t, err, tt.err)
+
+			var pe *ParseError
+			require.True(t, errors.As(err, &pe))
+			assert.Equal(t, tt.expected, *pe)
+			assert.True(t, errors.Is(err, strconv.ErrSyntax))
+		})
+	}
+
+	_, err := parseHeight("FL6O")
+	assert.EqualError(t, err, `invalid height "FL6O": invalid syntax`)
 }
 
 // TestResolveAirspaceType verifies type resolution for OTHER and D_OTHER
@@ -456,7 +628,7 @@ func TestDanger(t *testing.T) {
 	}
 }
 
-// TestDestinationPoint verifies spherical geometry calculations
+// TestDestinationPoint verifies geodesic calculations
 func TestDestinationPoint(t *testing.T) {
 	// Test with known values - going 1000m due north from a point
 	start := orb.Point{0.0, 50.0} // 0°E, 50°N
@@ -479,3 +651,49 @@ func TestDestinationPoint(t *testing.T) {
 	west := destinationPoint(start, 270, 1000)
 	assert.Less(t, west.Lon(), start.Lon(), "Going west should decrease longitude")
 }
+
+// TestDistanceToBoundary verifies distances to circle and polygon boundaries, from inside and outside
+func TestDistanceToBoundary(t *testing.T) {
+	centre := orb.Point{-1.0, 53.0}
+	circle := Volume{Circle: Circle{Radius: 5000, Centre: centre}}
+	assert.InDelta(t, 5000, DistanceToBoundary(centre, circle), 1)
+	assert.InDelta(t, 2000, DistanceToBoundary(destinationPoint(centre, 45, 3000), circle), 1)
+	assert.InDelta(t, 1000, DistanceToBoundary(destinationPoint(centre, 200, 6000), circle), 1)
+
+	square := Volume{Polygon: orb.Ring{
+		destinationPoint(centre, 0, 5000),
+		destinationPoint(centre, 90, 5000),
+		destinationPoint(centre, 180, 5000),
+		destinationPoint(centre, 270, 5000),
+		destinationPoint(centre, 0, 5000),
+	}}
+	// The nearest point on each side of the (diamond-shaped) square is 5000 / √2 m away.
+	assert.InDelta(t, 3535.5, DistanceToBoundary(centre, square), 10)
+	assert.InDelta(t, 3000, DistanceToBoundary(destinationPoint(centre, 0, 8000), square), 10)
+}
+
+func TestDecodeRelease(t *testing.T) {
+	release, err := DecodeRelease([]byte(`
+release:
+  airac_date: '2024-08-08T00:00:00Z'
+  timestamp: '2024-07-25T12:34:56+00:00'
+  schema_version: 1
+  note: Test release
+airspace: []
+`))
+	require.NoError(t, err)
+	assert.Equal(t, &Release{
+		AIRACDate:     "2024-08-08T00:00:00Z",
+		Timestamp:     "2024-07-25T12:34:56+00:00",
+		SchemaVersion: 1,
+		Note:          "Test release",
+	}, release)
+
+	release, err = DecodeRelease([]byte(data))
+	require.NoError(t, err)
+	assert.Nil(t, release)
+
+	release, err = DecodeRelease([]byte("AC D\nAN TEST\n"))
+	require.NoError(t, err)
+	assert.Nil(t, release)
+}
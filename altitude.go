@@ -0,0 +1,226 @@
+package airspace
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/paulmach/orb"
+)
+
+// Reference identifies what the vertical limit of a volume is measured relative to.
+type Reference string
+
+const (
+	RefAMSL Reference = "AMSL" // Above mean sea level, e.g. "3500 ft".
+	RefAGL  Reference = "AGL"  // Above ground level, e.g. "2000 ft SFC".
+	RefFL   Reference = "FL"   // Flight level, i.e. pressure altitude on 1013.25 hPa, e.g. "FL65".
+	RefSFC  Reference = "SFC"  // The surface.
+	RefUNL  Reference = "UNL"  // Unlimited.
+)
+
+// Unit is the unit an Altitude's Value is expressed in.
+type Unit string
+
+const (
+	Feet   Unit = "ft"
+	Metres Unit = "m"
+)
+
+// Altitude is a vertical limit of a volume, as published. Flight levels are held as the
+// equivalent pressure altitude in feet, so "FL65" has a Value of 6500.
+type Altitude struct {
+	Value     float64
+	Unit      Unit
+	Reference Reference
+}
+
+// Conditions describe the atmosphere and terrain needed to convert between altitude references.
+type Conditions struct {
+	QNH             float64 // Sea level pressure, in hPa.
+	GroundElevation float64 // Elevation of the ground, in feet AMSL.
+}
+
+// StandardPressure is the pressure setting, in hPa, that flight levels are measured from.
+const StandardPressure = 1013.25
+
+// StandardConditions assumes the ISA standard atmosphere and ground at sea level.
+var StandardConditions = Conditions{QNH: StandardPressure, GroundElevation: 0}
+
+const metresToFeet = 1 / 0.3048
+
+// Feet returns the altitude's value in feet, ignoring its reference.
+func (a Altitude) Feet() float64 {
+	if a.Unit == Metres {
+		return a.Value * metresToFeet
+	}
+	return a.Value
+}
+
+// FeetAMSL converts the altitude to feet above mean sea level, given the QNH and the ground
+// elevation in `c`. Unlimited altitudes are returned as +Inf.
+func (a Altitude) FeetAMSL(c Conditions) float64 {
+	switch a.Reference {
+	case RefSFC:
+		return c.GroundElevation
+	case RefAGL:
+		return c.GroundElevation + a.Feet()
+	case RefFL:
+		return pressureToAMSL(a.Feet(), c.QNH)
+	case RefUNL:
+		return math.Inf(+1)
+	default:
+		return a.Feet()
+	}
+}
+
+func (a Altitude) String() string {
+	switch a.Reference {
+	case RefSFC:
+		return "SFC"
+	case RefUNL:
+		return "UNL"
+	case RefFL:
+		return "FL" + strconv.FormatFloat(a.Feet()/100, 'f', -1, 64)
+	case RefAGL:
+		return fmt.Sprintf("%s %s SFC", strconv.FormatFloat(a.Value, 'f', -1, 64), a.Unit)
+	default:
+		return fmt.Sprintf("%s %s", strconv.FormatFloat(a.Value, 'f', -1, 64), a.Unit)
+	}
+}
+
+// jsonFeet returns the altitude in feet AMSL under StandardConditions, or nil if it is unlimited
+// (which JSON can't represent as a number).
+func jsonFeet(a Altitude) interface{} {
+	ft := a.FeetAMSL(StandardConditions)
+	if math.IsInf(ft, 0) {
+		return nil
+	}
+	return ft
+}
+
+// pressureToAMSL converts a pressure altitude to an altitude AMSL using the ISA pressure/height
+// relationship, so it stays accurate well away from standard pressure.
+func pressureToAMSL(pressureAltitudeFt float64, qnh float64) float64 {
+	if qnh <= 0 {
+		qnh = StandardPressure
+	}
+	return pressureAltitudeFt - 145366.45*(1-math.Pow(qnh/StandardPressure, 0.190263))
+}
+
+// parseHeight parses a yaixm height, which is one of "SFC", "UNL", "FL65", "3500 ft" (AMSL)
+// or "2000 ft SFC" (above the surface). Errors are of type *ParseError.
+func parseHeight(h string) (Altitude, error) {
+	h = strings.ToUpper(strings.TrimSpace(h))
+	switch h {
+	case "", "SFC", "GND":
+		return Altitude{Unit: Feet, Reference: RefSFC}, nil
+	case "UNL":
+		return Altitude{Unit: Feet, Reference: RefUNL}, nil
+	}
+
+	if strings.HasPrefix(h, "FL") {
+		// Flight level.
+		f, err := strconv.ParseFloat(h[2:], 64)
+		if err != nil {
+			return Altitude{}, &ParseError{Field: "height", Value: h, Err: numError(err)}
+		}
+		return Altitude{Value: f * 100, Unit: Feet, Reference: RefFL}, nil
+	}
+
+	alt := Altitude{Unit: Feet, Reference: RefAMSL}
+	if strings.HasSuffix(h, "SFC") || strings.HasSuffix(h, "AGL") {
+		alt.Reference = RefAGL
+		h = strings.TrimSpace(h[:len(h)-3])
+	}
+	if strings.HasSuffix(h, "FT") {
+		h = strings.TrimSpace(strings.TrimSuffix(h, "FT"))
+	} else if strings.HasSuffix(h, "M") {
+		alt.Unit = Metres
+		h = strings.TrimSpace(strings.TrimSuffix(h, "M"))
+	}
+
+	f, err := strconv.ParseFloat(h, 64)
+	if err != nil {
+		return Altitude{}, &ParseError{Field: "height", Value: h, Err: numError(err)}
+	}
+	alt.Value = f
+	return alt, nil
+}
+
+// Datum identifies what an altitude is measured relative to.
+type Datum int
+
+const (
+	// AMSL altitudes are measured above mean sea level, e.g. with the altimeter set to QNH, or from GNSS.
+	AMSL Datum = iota
+	// PressureAltitude altitudes are measured with the altimeter set to the standard pressure
+	// of 1013.25 hPa, as flight levels are.
+	PressureAltitude
+	// AGL altitudes are measured above the ground.
+	AGL
+)
+
+func (d Datum) String() string {
+	switch d {
+	case AMSL:
+		return "AMSL"
+	case PressureAltitude:
+		return "PressureAltitude"
+	case AGL:
+		return "AGL"
+	default:
+		return "Datum(?)"
+	}
+}
+
+// FeetAMSL converts an altitude measured relative to the datum into feet AMSL.
+func (d Datum) FeetAMSL(altitudeFt float64, c Conditions) float64 {
+	switch d {
+	case PressureAltitude:
+		return pressureToAMSL(altitudeFt, c.QNH)
+	case AGL:
+		return c.GroundElevation + altitudeFt
+	default:
+		return altitudeFt
+	}
+}
+
+// ContainsAltitude reports whether the given altitude lies between the volume's lower and
+// upper limits (inclusive), assuming StandardConditions.
+func (v Volume) ContainsAltitude(altitudeFt float64, datum Datum) bool {
+	return v.ContainsAltitudeWith(altitudeFt, datum, StandardConditions)
+}
+
+// ContainsAltitudeWith reports whether the given altitude lies between the volume's lower
+// and upper limits (inclusive), using `c` to convert everything to feet AMSL.
+func (v Volume) ContainsAltitudeWith(altitudeFt float64, datum Datum, c Conditions) bool {
+	alt := datum.FeetAMSL(altitudeFt, c)
+	return alt >= v.Lower.FeetAMSL(c) && alt <= v.Upper.FeetAMSL(c)
+}
+
+// QueryAt returns every volume enclosing `point` whose vertical limits include `altitudeFt`,
+// assuming StandardConditions.
+func (idx *Index) QueryAt(point orb.Point, altitudeFt float64, datum Datum) []Volume {
+	return idx.QueryAtWith(point, altitudeFt, datum, StandardConditions)
+}
+
+// QueryAtWith returns every volume enclosing `point` whose vertical limits include `altitudeFt`,
+// using `c` to convert altitudes to feet AMSL.
+func (idx *Index) QueryAtWith(point orb.Point, altitudeFt float64, datum Datum, c Conditions) []Volume {
+	enclosingVolumes := make([]Volume, 0)
+	for _, v := range idx.Query(point) {
+		if v.ContainsAltitudeWith(altitudeFt, datum, c) {
+			enclosingVolumes = append(enclosingVolumes, v)
+		}
+	}
+	return enclosingVolumes
+}
+
+// EnclosingVolumesAt is the three-dimensional equivalent of EnclosingVolumes, only returning
+// volumes whose vertical limits include `altitudeFt`. Like EnclosingVolumes, it tests every
+// volume.
+func EnclosingVolumesAt(point orb.Point, altitudeFt float64, datum Datum, features map[string]Feature) []Volume {
+	return sortByID(scanIndex(features).QueryAt(point, altitudeFt, datum))
+}
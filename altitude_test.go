@@ -0,0 +1,121 @@
+package airspace
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFeetAMSL verifies conversion of each kind of vertical limit to feet AMSL
+func TestFeetAMSL(t *testing.T) {
+	highPressure := Conditions{QNH: 1033, GroundElevation: 800}
+	tests := []struct {
+		name       string
+		alt        Altitude
+		conditions Conditions
+		want       float64
+	}{
+		{"AMSL", Altitude{3500, Feet, RefAMSL}, highPressure, 3500},
+		{"AMSL metres", Altitude{1000, Metres, RefAMSL}, StandardConditions, 3280.84},
+		{"Surface", Altitude{0, Feet, RefSFC}, highPressure, 800},
+		{"Above surface", Altitude{2000, Feet, RefAGL}, highPressure, 2800},
+		{"Flight level, standard pressure", Altitude{6500, Feet, RefFL}, StandardConditions, 6500},
+		{"Flight level, high pressure", Altitude{6500, Feet, RefFL}, highPressure, 6500 + 535},
+		{"Flight level, low pressure", Altitude{6500, Feet, RefFL}, Conditions{QNH: 993}, 6500 - 555},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, tt.alt.FeetAMSL(tt.conditions), 5)
+		})
+	}
+
+	assert.True(t, math.IsInf(Altitude{Reference: RefUNL}.FeetAMSL(StandardConditions), +1))
+}
+
+func TestAltitudeString(t *testing.T) {
+	for _, h := range []string{"SFC", "UNL", "FL65", "3500 ft", "2000 ft SFC", "600 m"} {
+		alt, err := parseHeight(h)
+		require.NoError(t, err)
+		assert.Equal(t, h, alt.String())
+	}
+}
+
+func TestVolumeJSONFeet(t *testing.T) {
+	vol := Volume{ID: "v", Name: "A & B", Lower: Altitude{3500, Feet, RefAMSL}, Upper: Altitude{Unit: Feet, Reference: RefUNL}}
+	b, err := json.Marshal(vol)
+	require.NoError(t, err)
+
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &m))
+	assert.Equal(t, "v", m["ID"])
+	assert.Equal(t, "A & B", m["Name"])
+	assert.Equal(t, 3500.0, m["LowerFeet"])
+	assert.Nil(t, m["UpperFeet"])
+	assert.Contains(t, m, "UpperFeet")
+	assert.Equal(t, map[string]interface{}{"Value": 3500.0, "Unit": "ft", "Reference": "AMSL"}, m["Lower"])
+
+	var decoded Volume
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, vol.Lower, decoded.Lower)
+	assert.Equal(t, vol.Upper, decoded.Upper)
+}
+
+// TestContainsAltitude verifies the vertical limits of a volume are inclusive
+func TestContainsAltitude(t *testing.T) {
+	vol := Volume{Lower: Altitude{3500, Feet, RefAMSL}, Upper: Altitude{6500, Feet, RefFL}}
+	tests := []struct {
+		name     string
+		altitude float64
+		datum    Datum
+		expected bool
+	}{
+		{"Below base", 500, AMSL, false},
+		{"At base", 3500, AMSL, true},
+		{"Inside", 5000, AMSL, true},
+		{"At top", 6500, AMSL, true},
+		{"Above top", 7000, AMSL, false},
+		{"Flight level inside", 5500, PressureAltitude, true},
+		{"Flight level above", 7500, PressureAltitude, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, vol.ContainsAltitude(tt.altitude, tt.datum))
+		})
+	}
+}
+
+// TestContainsAltitudeWith verifies that flight level and surface-relative limits move with
+// the QNH and ground elevation.
+func TestContainsAltitudeWith(t *testing.T) {
+	vol := Volume{Lower: Altitude{1000, Feet, RefAGL}, Upper: Altitude{6500, Feet, RefFL}}
+	lowPressure := Conditions{QNH: 993, GroundElevation: 1200}
+
+	assert.False(t, vol.ContainsAltitudeWith(2000, AMSL, lowPressure), "Only 800 ft above the ground")
+	assert.True(t, vol.ContainsAltitudeWith(2500, AMSL, lowPressure))
+	assert.True(t, vol.ContainsAltitudeWith(1500, AGL, lowPressure))
+	assert.False(t, vol.ContainsAltitudeWith(6200, AMSL, lowPressure), "FL65 is only about 5950 ft on a QNH of 993")
+	assert.True(t, vol.ContainsAltitudeWith(6200, AMSL, StandardConditions))
+	assert.True(t, vol.ContainsAltitudeWith(6200, PressureAltitude, lowPressure))
+}
+
+func TestQueryAt(t *testing.T) {
+	features, err := Decode([]byte(data))
+	require.NoError(t, err)
+	idx := NewIndex(features)
+	point := orb.Point{-2.2, 57.4} // Inside seqno 1, 1500 ft to FL115.
+
+	assert.Empty(t, idx.QueryAt(point, 500, AMSL), "Under the base of the CTA")
+	assert.Len(t, idx.QueryAt(point, 2000, AMSL), 1)
+	assert.Empty(t, idx.QueryAt(point, 12000, PressureAltitude), "Above the CTA")
+	assert.Len(t, idx.QueryAtWith(point, 11600, AMSL, Conditions{QNH: 1030}), 1, "FL115 is higher on a high QNH")
+
+	featureMap := map[string]Feature{features[0].ID: features[0]}
+	assert.Len(t, EnclosingVolumesAt(point, 2000, AMSL, featureMap), 1)
+	assert.Empty(t, EnclosingVolumesAt(point, 500, AMSL, featureMap))
+}
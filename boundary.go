@@ -0,0 +1,174 @@
+package airspace
+
+import (
+	"math"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb/planar"
+)
+
+const (
+	// circleStep is the angle, in degrees, between the vertices of the polygons approximating
+	// arcs and circles in Volume.Polygon.
+	circleStep = 10.0
+	// The limits on the angle between vertices when flattening to a tolerance.
+	minFlatteningStep = 0.1
+	maxFlatteningStep = 90.0
+)
+
+// startBearing returns the bearing, in degrees, from the arc's centre to its start.
+func (a Arc) startBearing() float64 {
+	return geo.Bearing(a.Centre, a.From)
+}
+
+// sweep returns the angle, in degrees, swept by the arc in its direction of travel.
+func (a Arc) sweep() float64 {
+	end := geo.Bearing(a.Centre, a.To)
+	if a.Clockwise {
+		return normaliseBearing(end - a.startBearing())
+	}
+	return normaliseBearing(a.startBearing() - end)
+}
+
+// covers reports whether the bearing (from the centre) lies within the arc's sweep.
+func (a Arc) covers(bearing float64) bool {
+	if a.Clockwise {
+		return normaliseBearing(bearing-a.startBearing()) <= a.sweep()
+	}
+	return normaliseBearing(a.startBearing()-bearing) <= a.sweep()
+}
+
+// midpoint returns the point halfway along the arc.
+func (a Arc) midpoint() orb.Point {
+	half := a.sweep() / 2
+	if !a.Clockwise {
+		half = -half
+	}
+	return destinationPoint(a.Centre, a.startBearing()+half, a.Radius)
+}
+
+// Bound returns the bounding box of the arc, including any part of it that bulges beyond
+// its end points.
+func (a Arc) Bound() orb.Bound {
+	b := orb.MultiPoint{a.From, a.To}.Bound()
+	for _, bearing := range []float64{0, 90, 180, 270} {
+		if a.covers(bearing) {
+			b = b.Extend(destinationPoint(a.Centre, bearing, a.Radius))
+		}
+	}
+	return b
+}
+
+// segmentContains reports whether `p` lies in the circular segment between the arc and its
+// chord.
+func (a Arc) segmentContains(p orb.Point, model EarthModel) bool {
+	if model.Distance(p, a.Centre) > a.Radius {
+		return false
+	}
+	return sideOf(a.From, a.To, p)*sideOf(a.From, a.To, a.midpoint()) >= 0
+}
+
+// sideOf returns a value whose sign tells which side of the line a-b `p` lies.
+func sideOf(a, b, p orb.Point) float64 {
+	return (b[0]-a[0])*(p[1]-a[1]) - (b[1]-a[1])*(p[0]-a[0])
+}
+
+func normaliseBearing(b float64) float64 {
+	b = math.Mod(b, 360)
+	if b < 0 {
+		b += 360
+	}
+	return b
+}
+
+// hasArcs reports whether the boundary includes any arcs.
+func hasArcs(boundary []Segment) bool {
+	for _, s := range boundary {
+		if s.Arc != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// boundaryContains reports whether `p` is inside a boundary made of lines and arcs, using the
+// arcs themselves rather than their flattened approximation.
+//
+// The polygon joining the ends of each segment is tested first. Each arc then adds the
+// circular segment between it and its chord if it bulges outwards, or removes it if it bulges
+// inwards; either way, `p` being inside that circular segment flips the result.
+func boundaryContains(boundary []Segment, p orb.Point, model EarthModel) bool {
+	var chords orb.Ring
+	for _, s := range boundary {
+		switch {
+		case s.Line != nil:
+			chords = append(chords, s.Line...)
+		case s.Arc != nil:
+			chords = append(chords, s.Arc.From, s.Arc.To)
+		}
+	}
+
+	inside := len(chords) > 2 && planar.RingContains(chords, p)
+	for _, s := range boundary {
+		if s.Arc != nil && s.Arc.segmentContains(p, model) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Flatten returns the volume's horizontal shape as a polygon, with arcs and circles replaced
+// by chords that are nowhere more than `tolerance` metres from the published boundary. A
+// tolerance of zero gives the same 10° steps used for Polygon.
+//
+// Volumes without a Boundary, such as those built by hand, return their Polygon or an
+// approximation of their Circle.
+func (v Volume) Flatten(tolerance float64) orb.Ring {
+	if len(v.Boundary) == 0 {
+		if len(v.Polygon) > 0 {
+			return v.Polygon.Clone()
+		}
+		if v.Circle.Radius != 0 {
+			return circleToRing(v.Circle, flatteningStep(v.Circle.Radius, tolerance))
+		}
+		return nil
+	}
+
+	var ring orb.Ring
+	for _, s := range v.Boundary {
+		switch {
+		case s.Line != nil:
+			ring = append(ring, s.Line...)
+		case s.Arc != nil:
+			dir := +1.0
+			if !s.Arc.Clockwise {
+				dir = -1.0
+			}
+			step := flatteningStep(s.Arc.Radius, tolerance)
+			ring = append(ring, arcToPolygonStep(s.Arc.Centre, s.Arc.Radius, s.Arc.From, s.Arc.To, dir, step)...)
+		case s.Circle != nil:
+			ring = append(ring, circleToRing(*s.Circle, flatteningStep(s.Circle.Radius, tolerance))...)
+		}
+	}
+	return ring
+}
+
+// flatteningStep returns the angle, in degrees, between vertices that keeps the chords of a
+// circle of `radius` metres within `tolerance` metres of it.
+func flatteningStep(radius, tolerance float64) float64 {
+	if tolerance <= 0 || radius <= 0 {
+		return circleStep
+	}
+	step := toDegrees(2 * math.Acos(math.Max(-1, 1-tolerance/radius)))
+	return math.Min(math.Max(step, minFlatteningStep), maxFlatteningStep)
+}
+
+// circleToRing approximates a circle by a closed polygon with a vertex every `step` degrees.
+func circleToRing(c Circle, step float64) orb.Ring {
+	var ring orb.Ring
+	for bearing := 0.0; bearing < 360; bearing += step {
+		ring = append(ring, destinationPoint(c.Centre, bearing, c.Radius))
+	}
+	return append(ring, ring[0])
+}
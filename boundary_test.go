@@ -0,0 +1,137 @@
+package airspace
+
+import (
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	arcCentre = orb.Point{-1.5, 53}
+	arcRadius = 10_000.0
+)
+
+// northernCap returns a volume bounded by a clockwise arc from bearing 275 to bearing 85 and
+// the chord joining its ends, so that due North is midway between two flattened vertices.
+func northernCap() Volume {
+	var vol Volume
+	bb := boundaryBuilder{vol: &vol}
+	bb.addPoint(destinationPoint(arcCentre, 275, arcRadius))
+	bb.addArc(arcCentre, arcRadius, true, destinationPoint(arcCentre, 85, arcRadius))
+	return vol
+}
+
+// bitten returns a rectangle south of the centre, with a semicircular bite out of its
+// northern side.
+func bitten() Volume {
+	var vol Volume
+	bb := boundaryBuilder{vol: &vol}
+	west := destinationPoint(arcCentre, 270, arcRadius)
+	east := destinationPoint(arcCentre, 90, arcRadius)
+	bb.addPoint(west)
+	bb.addPoint(orb.Point{west.Lon(), west.Lat() - 0.3})
+	bb.addPoint(orb.Point{east.Lon(), east.Lat() - 0.3})
+	bb.addPoint(east)
+	bb.addArc(arcCentre, arcRadius, true, west)
+	return vol
+}
+
+func TestExactArcContainment(t *testing.T) {
+	tests := []struct {
+		name     string
+		vol      Volume
+		bearing  float64
+		fraction float64 // Of the radius.
+		expected bool
+	}{
+		{"Inside the arc, beyond the chords", northernCap(), 0, 0.9999, true},
+		{"Just outside the arc", northernCap(), 0, 1.0001, false},
+		{"Well inside", northernCap(), 0, 0.5, true},
+		{"Beyond the chord", northernCap(), 180, 0.5, false},
+		{"Inside the bite", bitten(), 180, 0.5, false},
+		{"Inside the bite, beyond the chords", bitten(), 175, 0.999, false},
+		{"Just outside the bite", bitten(), 175, 1.001, true},
+		{"North of the rectangle", bitten(), 0, 0.5, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := destinationPoint(arcCentre, tt.bearing, tt.fraction*arcRadius)
+			assert.Equal(t, tt.expected, isEnclosedBy(p, tt.vol, WGS84))
+		})
+	}
+
+	// The flattened polygon gets the points between the chords and the arc wrong.
+	assert.False(t, planar.RingContains(northernCap().Polygon, destinationPoint(arcCentre, 0, 0.9999*arcRadius)))
+	assert.True(t, planar.RingContains(bitten().Polygon, destinationPoint(arcCentre, 175, 0.999*arcRadius)))
+}
+
+func TestIndexIncludesArcBulge(t *testing.T) {
+	vol := northernCap()
+	vol.ID = "cap"
+	idx := NewIndex([]Feature{{ID: "cap", Geometry: []Volume{vol}}})
+
+	p := destinationPoint(arcCentre, 0, 0.9999*arcRadius)
+	assert.Greater(t, p.Lat(), vol.Polygon.Bound().Max.Lat())
+	require.Len(t, idx.Query(p), 1)
+	assert.Equal(t, "cap", idx.Query(p)[0].ID)
+}
+
+func TestArcBound(t *testing.T) {
+	arc := northernCap().Boundary[1].Arc
+	require.NotNil(t, arc)
+
+	b := arc.Bound()
+	assert.InDelta(t, destinationPoint(arcCentre, 0, arcRadius).Lat(), b.Max.Lat(), 1e-12)
+	assert.InDelta(t, arc.From.Lat(), b.Min.Lat(), 1e-12)
+	assert.InDelta(t, arc.From.Lon(), b.Min.Lon(), 1e-12)
+	assert.InDelta(t, arc.To.Lon(), b.Max.Lon(), 1e-12)
+}
+
+func TestFlatten(t *testing.T) {
+	vol := northernCap()
+
+	// Zero tolerance gives the same vertices as Polygon.
+	assert.Equal(t, vol.Polygon, vol.Flatten(0))
+
+	for _, tolerance := range []float64{10, 1, 0.1} {
+		ring := vol.Flatten(tolerance)
+		assert.Greater(t, len(ring), len(vol.Polygon))
+
+		// The midpoint of each chord of the arc must be within tolerance of the arc.
+		for i := 1; i < len(ring)-1; i++ {
+			mid := orb.Point{(ring[i][0] + ring[i+1][0]) / 2, (ring[i][1] + ring[i+1][1]) / 2}
+			assert.InDelta(t, arcRadius, WGS84.Distance(arcCentre, mid), tolerance*1.01, "tolerance %v, vertex %d", tolerance, i)
+		}
+	}
+
+	circle := Volume{Circle: Circle{Radius: arcRadius, Centre: arcCentre}}
+	ring := circle.Flatten(1)
+	assert.Equal(t, ring[0], ring[len(ring)-1])
+	assert.Greater(t, len(ring), 100)
+
+	assert.Nil(t, Volume{}.Flatten(1))
+}
+
+func TestFlatteningStep(t *testing.T) {
+	tests := []struct {
+		name      string
+		radius    float64
+		tolerance float64
+		expected  float64
+	}{
+		{"Default", 10_000, 0, circleStep},
+		{"Coarse", 10_000, 10_000, maxFlatteningStep},
+		{"Fine", 10_000, 1e-9, minFlatteningStep},
+		{"Chord of 10 degrees", 10_000, 10_000 * (1 - 0.9961946980917455), 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.expected, flatteningStep(tt.radius, tt.tolerance), 1e-6)
+		})
+	}
+}
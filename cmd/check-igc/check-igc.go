@@ -0,0 +1,143 @@
+// check-igc reports every airspace infringement in one or more IGC flight logs.
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	airspace "github.com/paulcager/gb-airspace"
+	flag "github.com/spf13/pflag"
+)
+
+const feetPerMetre = 1 / 0.3048
+
+var (
+	airspaceFile    string
+	altitudeSource  string
+	qnh             float64
+	groundElevation float64
+)
+
+// Infringement is a single passage through a volume requiring clearance, or a danger area.
+type Infringement struct {
+	Volume airspace.Volume
+	// Times of the first and last fixes inside the volume.
+	Entry time.Time
+	Exit  time.Time
+	// How far inside the volume the flight went: vertically in feet (the distance to the
+	// nearer of the lower and upper limits) and horizontally in metres (to the boundary).
+	MaxVerticalPenetration   float64
+	MaxHorizontalPenetration float64
+}
+
+func main() {
+	flag.StringVarP(&airspaceFile, "airspace", "a", "airspace.yaml", "airspace.yaml file")
+	flag.StringVar(&altitudeSource, "altitude", "pressure", "Altitude to check: pressure or gnss")
+	flag.Float64Var(&qnh, "qnh", airspace.StandardPressure, "QNH (hPa) used to convert pressure altitudes and flight levels")
+	flag.Float64Var(&groundElevation, "ground-elevation", 0, "Ground elevation (ft) assumed for surface-relative limits")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] file.igc...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 || (altitudeSource != "pressure" && altitudeSource != "gnss") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	features, err := airspace.LoadFile(airspaceFile)
+	if err != nil {
+		log.Fatalf("Could not load %s: %s", airspaceFile, err)
+	}
+	idx := airspace.NewIndex(features)
+	conditions := airspace.Conditions{QNH: qnh, GroundElevation: groundElevation}
+
+	found := false
+	for _, fileName := range flag.Args() {
+		fixes, err := loadIGC(fileName)
+		if err != nil {
+			log.Fatalf("Could not read %s: %s", fileName, err)
+		}
+		infringements := findInfringements(idx, fixes, altitudeSource == "gnss", conditions)
+		printReport(fileName, infringements)
+		found = found || len(infringements) > 0
+	}
+
+	if found {
+		os.Exit(1)
+	}
+}
+
+func loadIGC(fileName string) ([]Fix, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseIGC(file)
+}
+
+// findInfringements runs each fix through the index, grouping consecutive fixes inside the
+// same volume into a single infringement.
+func findInfringements(idx *airspace.Index, fixes []Fix, useGNSS bool, c airspace.Conditions) []*Infringement {
+	var infringements []*Infringement
+	open := make(map[string]*Infringement)
+
+	for _, fix := range fixes {
+		alt, datum := fix.PressureAltitude*feetPerMetre, airspace.PressureAltitude
+		if useGNSS {
+			if !fix.Valid {
+				// No reliable GNSS altitude.
+				continue
+			}
+			alt, datum = fix.GNSSAltitude*feetPerMetre, airspace.AMSL
+		}
+		altAMSL := datum.FeetAMSL(alt, c)
+
+		inside := make(map[string]*Infringement)
+		for _, v := range idx.QueryAtWith(fix.Point, alt, datum, c) {
+			if !v.ClearanceRequired && !v.Danger {
+				continue
+			}
+
+			key := fmt.Sprintf("%s/%d", v.ID, v.Sequence)
+			inf, ok := open[key]
+			if !ok {
+				inf = &Infringement{Volume: v, Entry: fix.Time}
+				infringements = append(infringements, inf)
+			}
+			inside[key] = inf
+
+			inf.Exit = fix.Time
+			vertical := math.Min(altAMSL-v.Lower.FeetAMSL(c), v.Upper.FeetAMSL(c)-altAMSL)
+			inf.MaxVerticalPenetration = math.Max(inf.MaxVerticalPenetration, vertical)
+			inf.MaxHorizontalPenetration = math.Max(inf.MaxHorizontalPenetration, airspace.DistanceToBoundary(fix.Point, v))
+		}
+		open = inside
+	}
+
+	return infringements
+}
+
+func printReport(fileName string, infringements []*Infringement) {
+	fmt.Printf("%s: %d infringement(s)\n", fileName, len(infringements))
+	if len(infringements) == 0 {
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tCLASS\tTYPE\tLOWER\tUPPER\tENTRY\tEXIT\tVERTICAL\tHORIZONTAL")
+	for _, inf := range infringements {
+		v := inf.Volume
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%.0f ft\t%.0f m\n",
+			v.ID, v.Name, v.Class, v.Type, v.Lower, v.Upper,
+			inf.Entry.Format("15:04:05"), inf.Exit.Format("15:04:05"),
+			inf.MaxVerticalPenetration, inf.MaxHorizontalPenetration)
+	}
+	w.Flush()
+}
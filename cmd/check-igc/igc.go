@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paulmach/orb"
+)
+
+// Fix is a single position from an IGC B-record.
+type Fix struct {
+	Time             time.Time
+	Point            orb.Point
+	Valid            bool    // False if the logger reported a 2D (or no) GNSS fix.
+	PressureAltitude float64 // Metres, relative to 1013.25 hPa.
+	GNSSAltitude     float64 // Metres above the WGS84 ellipsoid (or geoid, depending on the logger).
+}
+
+// midnightRollover is how far a fix's time of day must go back from the previous fix's to be
+// taken as passing midnight UTC, rather than as a repeated second or the logger's clock
+// jittering.
+const midnightRollover = 12 * time.Hour
+
+// ParseIGC reads the B-records from an IGC file. The date of the flight is taken from the
+// HFDTE header record, and times that wrap past midnight UTC are moved on to the next day.
+func ParseIGC(r io.Reader) ([]Fix, error) {
+	var (
+		fixes []Fix
+		date  time.Time
+		last  time.Time
+	)
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r\n")
+		switch {
+		case strings.HasPrefix(line, "HFDTE"):
+			d, err := parseIGCDate(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			date = d
+		case strings.HasPrefix(line, "B"):
+			fix, err := parseBRecord(line, date)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			if !last.IsZero() {
+				// Put the fix on the same day as the last one, unless that's half a day out.
+				t := fix.Time
+				fix.Time = time.Date(last.Year(), last.Month(), last.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+				switch {
+				case last.Sub(fix.Time) > midnightRollover:
+					fix.Time = fix.Time.AddDate(0, 0, 1)
+				case fix.Time.Sub(last) > midnightRollover:
+					fix.Time = fix.Time.AddDate(0, 0, -1)
+				}
+			}
+			last = fix.Time
+			fixes = append(fixes, fix)
+		}
+	}
+
+	return fixes, scanner.Err()
+}
+
+// parseIGCDate parses "HFDTEDDMMYY" or the newer "HFDTEDATE:DDMMYY,NN".
+func parseIGCDate(line string) (time.Time, error) {
+	s := strings.TrimPrefix(line, "HFDTE")
+	s = strings.TrimPrefix(s, "DATE:")
+	if len(s) < 6 {
+		return time.Time{}, fmt.Errorf("bad date record %q", line)
+	}
+	d, err := time.Parse("020106", s[:6])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad date record %q: %w", line, err)
+	}
+	return d, nil
+}
+
+// parseBRecord parses a fix in the format "BHHMMSSDDMMmmmNDDDMMmmmEVPPPPPGGGGG".
+func parseBRecord(line string, date time.Time) (Fix, error) {
+	if len(line) < 35 {
+		return Fix{}, fmt.Errorf("B record too short: %q", line)
+	}
+
+	hh, err1 := strconv.Atoi(line[1:3])
+	mm, err2 := strconv.Atoi(line[3:5])
+	ss, err3 := strconv.Atoi(line[5:7])
+	if err1 != nil || err2 != nil || err3 != nil {
+		return Fix{}, fmt.Errorf("bad time in B record %q", line)
+	}
+
+	lat, err := parseIGCAngle(line[7:14], line[14], 'N', 'S')
+	if err != nil {
+		return Fix{}, fmt.Errorf("bad latitude in B record %q: %w", line, err)
+	}
+	lon, err := parseIGCAngle(line[15:23], line[23], 'E', 'W')
+	if err != nil {
+		return Fix{}, fmt.Errorf("bad longitude in B record %q: %w", line, err)
+	}
+
+	pressureAlt, err1 := strconv.Atoi(line[25:30])
+	gnssAlt, err2 := strconv.Atoi(line[30:35])
+	if err1 != nil || err2 != nil {
+		return Fix{}, fmt.Errorf("bad altitude in B record %q", line)
+	}
+
+	return Fix{
+		Time:             time.Date(date.Year(), date.Month(), date.Day(), hh, mm, ss, 0, time.UTC),
+		Point:            orb.Point{lon, lat},
+		Valid:            line[24] == 'A',
+		PressureAltitude: float64(pressureAlt),
+		GNSSAltitude:     float64(gnssAlt),
+	}, nil
+}
+
+// parseIGCAngle parses degrees followed by minutes with 3 implied decimal places, e.g.
+// "5206343" is 52° 06.343'.
+func parseIGCAngle(s string, hemisphere byte, positive, negative byte) (float64, error) {
+	degDigits := len(s) - 5
+	deg, err1 := strconv.Atoi(s[:degDigits])
+	thousandthsOfMinutes, err2 := strconv.Atoi(s[degDigits:])
+	if err1 != nil || err2 != nil {
+		return 0, fmt.Errorf("bad angle %q", s)
+	}
+
+	angle := float64(deg) + float64(thousandthsOfMinutes)/1000/60
+	switch hemisphere {
+	case positive:
+		return angle, nil
+	case negative:
+		return -angle, nil
+	default:
+		return 0, fmt.Errorf("bad hemisphere %q", hemisphere)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	airspace "github.com/paulcager/gb-airspace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const igc = `AXXX001 Test logger
+HFDTE150721
+HFPLTPILOTINCHARGE: Test Pilot
+B1159505722000N00225000WA0030000320
+B1200005724000N00220000WA0060000620
+B1200105724000N00215000WA0061000630
+B1200205724000N00150000WA0062000640
+B2359595722000N00225000WA0030000320
+B0000105722000N00225000WV0030000000
+`
+
+func TestParseIGC(t *testing.T) {
+	fixes, err := ParseIGC(strings.NewReader(igc))
+	require.NoError(t, err)
+	require.Len(t, fixes, 6)
+
+	f := fixes[1]
+	assert.Equal(t, time.Date(2021, 7, 15, 12, 0, 0, 0, time.UTC), f.Time)
+	assert.InDelta(t, 57.4, f.Point.Lat(), 1e-9)
+	assert.InDelta(t, -(2 + 20.0/60), f.Point.Lon(), 1e-9)
+	assert.True(t, f.Valid)
+	assert.Equal(t, 600.0, f.PressureAltitude)
+	assert.Equal(t, 620.0, f.GNSSAltitude)
+
+	assert.False(t, fixes[5].Valid)
+	assert.Equal(t, time.Date(2021, 7, 16, 0, 0, 10, 0, time.UTC), fixes[5].Time, "Should roll over midnight")
+}
+
+func TestParseIGCClockStepsBack(t *testing.T) {
+	fixes, err := ParseIGC(strings.NewReader(`HFDTE150721
+B1200105724000N00215000WA0061000630
+B1200095724000N00215000WA0061000630
+B1200105724000N00215000WA0061000630
+B2359595722000N00225000WA0030000320
+B0000005722000N00225000WA0030000320
+B2359595722000N00225000WA0030000320
+B0000015722000N00225000WA0030000320
+`))
+	require.NoError(t, err)
+	require.Len(t, fixes, 7)
+
+	// A second back is jitter, not a day later.
+	assert.Equal(t, time.Date(2021, 7, 15, 12, 0, 9, 0, time.UTC), fixes[1].Time)
+	assert.Equal(t, time.Date(2021, 7, 15, 12, 0, 10, 0, time.UTC), fixes[2].Time)
+	assert.Equal(t, time.Date(2021, 7, 16, 0, 0, 0, 0, time.UTC), fixes[4].Time)
+	assert.Equal(t, time.Date(2021, 7, 15, 23, 59, 59, 0, time.UTC), fixes[5].Time, "Jitter across midnight")
+	assert.Equal(t, time.Date(2021, 7, 16, 0, 0, 1, 0, time.UTC), fixes[6].Time)
+}
+
+func TestParseIGCErrors(t *testing.T) {
+	_, err := ParseIGC(strings.NewReader("HFDTE150721\nB1159505722000N00225000WA00300\n"))
+	assert.Error(t, err, "Short B record")
+
+	_, err = ParseIGC(strings.NewReader("HFDTE150721\nB1159505722000X00225000WA0030000320\n"))
+	assert.Error(t, err, "Bad hemisphere")
+
+	_, err = ParseIGC(strings.NewReader("HFDTEXX0721\n"))
+	assert.Error(t, err, "Bad date")
+}
+
+func TestParseIGCDate(t *testing.T) {
+	d, err := parseIGCDate("HFDTEDATE:150721,01")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2021, 7, 15, 0, 0, 0, 0, time.UTC), d)
+}
+
+func TestFindInfringements(t *testing.T) {
+	features, err := airspace.Decode([]byte(`
+airspace:
+- name: TEST CTA
+  id: test-cta
+  type: CTA
+  class: D
+  geometry:
+  - seqno: 1
+    upper: FL65
+    lower: 1500 ft
+    boundary:
+    - circle:
+        radius: 5 nm
+        centre: 572400N 0021800W
+`))
+	require.NoError(t, err)
+	idx := airspace.NewIndex(features)
+
+	fixes, err := ParseIGC(strings.NewReader(igc))
+	require.NoError(t, err)
+
+	infringements := findInfringements(idx, fixes, false, airspace.StandardConditions)
+	require.Len(t, infringements, 1)
+	inf := infringements[0]
+	assert.Equal(t, "test-cta", inf.Volume.ID)
+	assert.Equal(t, time.Date(2021, 7, 15, 12, 0, 0, 0, time.UTC), inf.Entry)
+	assert.Equal(t, time.Date(2021, 7, 15, 12, 0, 10, 0, time.UTC), inf.Exit)
+	assert.InDelta(t, 610*feetPerMetre-1500, inf.MaxVerticalPenetration, 1)
+	assert.Greater(t, inf.MaxHorizontalPenetration, 0.0)
+
+	// The first fix, at 300 m, is under the base.
+	assert.Empty(t, findInfringements(idx, fixes[:1], true, airspace.StandardConditions))
+}
@@ -0,0 +1,87 @@
+// lint-airspace checks airspace data for problems, reporting every one it finds rather than
+// stopping at the first. It exits with status 1 if there are any errors.
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	airspace "github.com/paulcager/gb-airspace"
+	flag "github.com/spf13/pflag"
+)
+
+var (
+	dataURL    string
+	errorsOnly bool
+)
+
+func main() {
+	flag.StringVarP(&dataURL, "airspace-url", "u", "https://raw.githubusercontent.com/ahsparrow/airspace/master/airspace.yaml", "airspace.yaml (or OpenAir) URL or file name")
+	flag.BoolVar(&errorsOnly, "errors-only", false, "Don't report warnings")
+	flag.Parse()
+
+	b, err := read(dataURL)
+	if err != nil {
+		log.Fatalf("Could not load %s: %s", dataURL, err)
+	}
+	issues, err := lint(b)
+	if err != nil {
+		log.Fatalf("Could not decode %s: %s", dataURL, err)
+	}
+
+	if printReport(os.Stdout, issues, errorsOnly) > 0 {
+		os.Exit(1)
+	}
+}
+
+func read(name string) ([]byte, error) {
+	if !strings.HasPrefix(name, "http://") && !strings.HasPrefix(name, "https://") {
+		return ioutil.ReadFile(name)
+	}
+	resp, err := http.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", name, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// lint decodes the data, collecting every problem, and validates the result.
+func lint(b []byte) ([]airspace.Issue, error) {
+	features, issues, err := airspace.DecodeLenient(b)
+	if err != nil {
+		return nil, err
+	}
+	return append(issues, airspace.Validate(features)...), nil
+}
+
+// printReport writes the issues as a table, returning the number of errors.
+func printReport(out io.Writer, issues []airspace.Issue, errorsOnly bool) int {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "SEVERITY\tFEATURE\tVOLUME\tSEQ\tCODE\tMESSAGE")
+	errors, warnings := 0, 0
+	for _, i := range issues {
+		if i.Severity == airspace.SeverityError {
+			errors++
+		} else if warnings++; errorsOnly {
+			continue
+		}
+		seq := ""
+		if i.Sequence != 0 {
+			seq = fmt.Sprint(i.Sequence)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", i.Severity, i.FeatureID, i.VolumeID, seq, i.Code, i.Message)
+	}
+	w.Flush()
+	fmt.Fprintf(out, "%d errors, %d warnings\n", errors, warnings)
+	return errors
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	airspace "github.com/paulcager/gb-airspace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const data = `
+airspace:
+- name: ALPHA
+  id: alpha
+  type: D
+  geometry:
+  - seqno: 1
+    upper: 2000 ft
+    lower: 3000 ft
+    boundary:
+    - circle:
+        radius: 2 nm
+        centre: 520000N 0010000W
+- name: ALPHA
+  id: alpha
+  type: D
+  geometry:
+  - upper: FLxx
+    lower: SFC
+    boundary:
+    - circle:
+        radius: 2 nm
+        centre: 530000N 0010000W
+`
+
+func TestLint(t *testing.T) {
+	issues, err := lint([]byte(data))
+	require.NoError(t, err)
+
+	var codes []string
+	for _, i := range issues {
+		codes = append(codes, i.Code)
+	}
+	assert.Equal(t, []string{airspace.IssueBadHeight, airspace.IssueLowerAboveUpper, airspace.IssueDuplicateID}, codes)
+
+	_, err = lint([]byte("airspace: [\n"))
+	assert.Error(t, err)
+}
+
+func TestPrintReport(t *testing.T) {
+	issues := []airspace.Issue{
+		{Severity: airspace.SeverityError, Code: airspace.IssueDuplicateID, FeatureID: "alpha", Message: "duplicate"},
+		{Severity: airspace.SeverityWarning, Code: airspace.IssueArcRadius, FeatureID: "bravo", VolumeID: "bravo", Sequence: 2, Message: "off radius"},
+	}
+
+	var out bytes.Buffer
+	assert.Equal(t, 1, printReport(&out, issues, false))
+	assert.Equal(t, `SEVERITY  FEATURE  VOLUME  SEQ  CODE                 MESSAGE
+error     alpha                 duplicate-id         duplicate
+warning   bravo    bravo   2    arc-radius-mismatch  off radius
+1 errors, 1 warnings
+`, out.String())
+
+	out.Reset()
+	printReport(&out, issues, true)
+	assert.NotContains(t, out.String(), "bravo")
+	assert.Contains(t, out.String(), "1 errors, 1 warnings")
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	airspace "github.com/paulcager/gb-airspace"
+)
+
+const (
+	initialRetryDelay = 10 * time.Second
+	maxRetryDelay     = 10 * time.Minute
+)
+
+// dataset is one complete, immutable load of the airspace. Reloads build a new dataset and
+// swap it in, so a request that has called currentDataset can keep using it safely.
+type dataset struct {
+	features    map[string]airspace.Feature
+	featureList []airspace.Feature
+	index       *airspace.Index
+	// When the data was fetched; if it came from more than one URL, the oldest fetch.
+	fetched time.Time
+	// Whether any of the data came from a snapshot rather than the network.
+	fromSnapshot bool
+	// The airspace source, followed by any RATs, any services and then any schedules.
+	sources []sourceInfo
+	release *airspace.Release
+}
+
+// sourceInfo describes the data fetched from one URL.
+type sourceInfo struct {
+	URL          string
+	Fetched      time.Time
+	SHA256       string
+	FromSnapshot bool
+}
+
+// source is the data fetched from one URL.
+type source struct {
+	url          string
+	data         []byte
+	fetched      time.Time
+	fromSnapshot bool
+}
+
+var (
+	dataMu sync.RWMutex
+	data   *dataset
+
+	// reloadMu stops concurrent reloads from fetching the data more than once at a time.
+	reloadMu sync.Mutex
+
+	statusMu    sync.Mutex
+	lastAttempt time.Time
+	lastError   error
+
+	httpClient = &http.Client{Timeout: time.Minute}
+)
+
+func currentDataset() *dataset {
+	dataMu.RLock()
+	defer dataMu.RUnlock()
+	return data
+}
+
+func setDataset(ds *dataset) {
+	dataMu.Lock()
+	defer dataMu.Unlock()
+	data = ds
+}
+
+func newDataset(featureList []airspace.Feature) *dataset {
+	features := make(map[string]airspace.Feature, len(featureList))
+	for _, f := range featureList {
+		if _, ok := features[f.ID]; ok {
+			log.Printf("Duplicate feature ID %q. Lookups will be undefined", f.ID)
+		}
+		features[f.ID] = f
+	}
+	return &dataset{
+		features:    features,
+		featureList: featureList,
+		index:       airspace.NewIndex(featureList),
+	}
+}
+
+// loadDataset fetches the airspace, any RATs, any LARS and FIS coverage and any activation
+// schedules. If allowSnapshot is true, any URL that can't be fetched is read from its snapshot
+// instead. Snapshots are updated once all of the data has been decoded successfully.
+func loadDataset(allowSnapshot bool) (*dataset, error) {
+	src, err := fetch(dataURL, allowSnapshot)
+	if err != nil {
+		return nil, err
+	}
+	featureList, err := airspace.DecodeAny(src.data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", src.url, err)
+	}
+	release, err := airspace.DecodeRelease(src.data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", src.url, err)
+	}
+
+	sources := []source{src}
+	// RAT IDs are generated from their names, so two RATs may clash with each other or with the
+	// airspace. Merge would silently replace one with the other.
+	loadedFrom := make(map[string]string, len(featureList))
+	for _, f := range featureList {
+		loadedFrom[f.ID] = src.url
+	}
+	merge := func(kind string, features []airspace.Feature, src source) error {
+		for _, f := range features {
+			if url, ok := loadedFrom[f.ID]; ok {
+				return fmt.Errorf("%s %q from %s has the same ID as a feature from %s", kind, f.ID, src.url, url)
+			}
+			loadedFrom[f.ID] = src.url
+		}
+		featureList = airspace.Merge(featureList, features)
+		sources = append(sources, src)
+		return nil
+	}
+	for _, ratURL := range ratURLs {
+		rats, src, err := loadRAT(ratURL, allowSnapshot)
+		if err != nil {
+			return nil, err
+		}
+		if err := merge("RAT", rats, src); err != nil {
+			return nil, err
+		}
+	}
+	// yaixm has no LARS coverage, and the library's FIS sectors are only approximate, so they
+	// may be loaded separately.
+	for _, servicesURL := range servicesURLs {
+		src, err := fetch(servicesURL, allowSnapshot)
+		if err != nil {
+			return nil, err
+		}
+		services, err := airspace.Decode(src.data)
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", src.url, err)
+		}
+		if err := merge("Service", services, src); err != nil {
+			return nil, err
+		}
+	}
+	// yaixm doesn't publish the hours of airspace such as danger areas, only that they may be
+	// activated by NOTAM.
+	if schedulesURL != "" {
+		src, err := fetch(schedulesURL, allowSnapshot)
+		if err != nil {
+			return nil, err
+		}
+		schedules, err := airspace.DecodeSchedules(src.data)
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", src.url, err)
+		}
+		for _, id := range airspace.SetSchedules(featureList, schedules) {
+			log.Printf("Schedule for %q from %s matches no feature or volume", id, src.url)
+		}
+		sources = append(sources, src)
+	}
+
+	ds := newDataset(featureList)
+	ds.release = release
+	for i, src := range sources {
+		hash := sha256.Sum256(src.data)
+		ds.sources = append(ds.sources, sourceInfo{
+			URL:          src.url,
+			Fetched:      src.fetched,
+			SHA256:       hex.EncodeToString(hash[:]),
+			FromSnapshot: src.fromSnapshot,
+		})
+		if i == 0 || src.fetched.Before(ds.fetched) {
+			ds.fetched = src.fetched
+		}
+		if src.fromSnapshot {
+			ds.fromSnapshot = true
+		} else {
+			saveSnapshot(src)
+		}
+	}
+	return ds, nil
+}
+
+// initialLoad loads the data at startup, falling back to snapshots if necessary. Unless the
+// data came from the network, it keeps retrying in the background until it does.
+func initialLoad() {
+	ds, err := loadDataset(true)
+	recordAttempt(err)
+	if err != nil {
+		log.Printf("Failed to load airspace data, will retry: %s", err)
+	} else {
+		setDataset(ds)
+		log.Printf("Loaded %d features", len(ds.features))
+	}
+
+	if ds == nil || ds.fromSnapshot {
+		go retryWithBackoff(initialRetryDelay, maxRetryDelay)
+	}
+}
+
+// retryWithBackoff reloads the data, doubling the delay between attempts up to `max`, until
+// a reload succeeds.
+func retryWithBackoff(initial, max time.Duration) {
+	delay := initial
+	for {
+		time.Sleep(delay)
+		if _, err := reload(); err == nil {
+			return
+		}
+		if delay *= 2; delay > max {
+			delay = max
+		}
+	}
+}
+
+// fetch downloads `url`, or if that fails and allowSnapshot is true, reads its snapshot.
+func fetch(url string, allowSnapshot bool) (source, error) {
+	b, err := download(url)
+	if err == nil {
+		return source{url: url, data: b, fetched: time.Now()}, nil
+	}
+	if !allowSnapshot || snapshotDir == "" {
+		return source{}, err
+	}
+
+	fileName := snapshotFile(url)
+	b, snapshotErr := ioutil.ReadFile(fileName)
+	if snapshotErr != nil {
+		return source{}, fmt.Errorf("%w (no snapshot: %s)", err, snapshotErr)
+	}
+	info, snapshotErr := os.Stat(fileName)
+	if snapshotErr != nil {
+		return source{}, fmt.Errorf("%w (no snapshot: %s)", err, snapshotErr)
+	}
+
+	log.Printf("Failed to fetch %s (%s); using snapshot from %s", url, err, info.ModTime().UTC().Format(time.RFC3339))
+	return source{url: url, data: b, fetched: info.ModTime(), fromSnapshot: true}, nil
+}
+
+func download(url string) ([]byte, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// snapshotFile returns the name of the snapshot of the data from `url`.
+func snapshotFile(url string) string {
+	hash := sha256.Sum256([]byte(url))
+	return filepath.Join(snapshotDir, hex.EncodeToString(hash[:8])+".snapshot")
+}
+
+// saveSnapshot writes the source's data to its snapshot file, replacing the old snapshot
+// atomically so that a crash can't leave a partial file behind.
+func saveSnapshot(src source) {
+	if snapshotDir == "" {
+		return
+	}
+	if err := os.MkdirAll(snapshotDir, 0755); err != nil {
+		log.Printf("Failed to save snapshot of %s: %s", src.url, err)
+		return
+	}
+
+	tmp, err := ioutil.TempFile(snapshotDir, "tmp-")
+	if err != nil {
+		log.Printf("Failed to save snapshot of %s: %s", src.url, err)
+		return
+	}
+	_, err = tmp.Write(src.data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), snapshotFile(src.url))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Printf("Failed to save snapshot of %s: %s", src.url, err)
+	}
+}
+
+func recordAttempt(err error) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	lastAttempt = time.Now()
+	lastError = err
+}
+
+// status is the response to /v4/status.
+type status struct {
+	Loaded       bool
+	FromSnapshot bool
+	Fetched      *time.Time `json:",omitempty"`
+	AgeSeconds   float64
+	Features     int
+	LastAttempt  time.Time
+	LastError    string `json:",omitempty"`
+}
+
+func currentStatus(now time.Time) status {
+	statusMu.Lock()
+	st := status{LastAttempt: lastAttempt}
+	if lastError != nil {
+		st.LastError = lastError.Error()
+	}
+	statusMu.Unlock()
+
+	if ds := currentDataset(); ds != nil {
+		st.Loaded = true
+		st.FromSnapshot = ds.fromSnapshot
+		st.Fetched = &ds.fetched
+		st.AgeSeconds = now.Sub(ds.fetched).Seconds()
+		st.Features = len(ds.features)
+	}
+	return st
+}
+
+// version is the response to /v4/airspace/version. The URL, fetch time and hash are those of
+// the main airspace data; Sources also includes any RATs.
+type version struct {
+	URL          string
+	Fetched      time.Time
+	SHA256       string
+	FromSnapshot bool
+	Features     int
+	Volumes      int
+	Release      *airspace.Release `json:",omitempty"`
+	Sources      []sourceInfo
+}
+
+func (ds *dataset) version() version {
+	v := version{
+		Features: len(ds.features),
+		Release:  ds.release,
+		Sources:  ds.sources,
+	}
+	for _, f := range ds.featureList {
+		v.Volumes += len(f.Geometry)
+	}
+	if len(ds.sources) > 0 {
+		v.URL = ds.sources[0].URL
+		v.Fetched = ds.sources[0].Fetched
+		v.SHA256 = ds.sources[0].SHA256
+		v.FromSnapshot = ds.sources[0].FromSnapshot
+	}
+	return v
+}
+
+// reloadSummary describes the changes made by a reload.
+type reloadSummary struct {
+	Features int
+	Added    []string
+	Removed  []string
+}
+
+// reload fetches the data again and, if that succeeds, swaps it in. If it fails the existing
+// data is kept.
+func reload() (reloadSummary, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	ds, err := loadDataset(false)
+	recordAttempt(err)
+	if err != nil {
+		log.Printf("Reload failed, keeping existing data: %s", err)
+		return reloadSummary{}, err
+	}
+
+	summary := diffDatasets(currentDataset(), ds)
+	setDataset(ds)
+
+	log.Printf("Reloaded %d features: %d added, %d removed", summary.Features, len(summary.Added), len(summary.Removed))
+	for _, id := range summary.Added {
+		log.Printf("Added feature %q", id)
+	}
+	for _, id := range summary.Removed {
+		log.Printf("Removed feature %q", id)
+	}
+	return summary, nil
+}
+
+// diffDatasets returns the IDs of features added and removed between old (which may be nil)
+// and new.
+func diffDatasets(old, new *dataset) reloadSummary {
+	summary := reloadSummary{Features: len(new.features), Added: []string{}, Removed: []string{}}
+	var oldFeatures map[string]airspace.Feature
+	if old != nil {
+		oldFeatures = old.features
+	}
+	for id := range new.features {
+		if _, ok := oldFeatures[id]; !ok {
+			summary.Added = append(summary.Added, id)
+		}
+	}
+	for id := range oldFeatures {
+		if _, ok := new.features[id]; !ok {
+			summary.Removed = append(summary.Removed, id)
+		}
+	}
+	sort.Strings(summary.Added)
+	sort.Strings(summary.Removed)
+	return summary
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	airspace "github.com/paulcager/gb-airspace"
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	dataV1 = `
+airspace:
+- name: ALPHA
+  id: alpha
+  type: D
+  geometry:
+  - upper: 2000 ft
+    lower: SFC
+    boundary:
+    - circle:
+        radius: 2 nm
+        centre: 520000N 0010000W
+- name: BRAVO
+  id: bravo
+  type: D
+  geometry:
+  - upper: 2000 ft
+    lower: SFC
+    boundary:
+    - circle:
+        radius: 2 nm
+        centre: 530000N 0010000W
+`
+	dataV2 = `
+airspace:
+- name: BRAVO
+  id: bravo
+  type: D
+  geometry:
+  - upper: 2000 ft
+    lower: SFC
+    boundary:
+    - circle:
+        radius: 2 nm
+        centre: 530000N 0010000W
+- name: CHARLIE
+  id: charlie
+  type: D
+  geometry:
+  - upper: 2000 ft
+    lower: SFC
+    boundary:
+    - circle:
+        radius: 2 nm
+        centre: 540000N 0010000W
+`
+)
+
+// serveData starts a server returning whichever of dataV1 or dataV2 `version` selects, and
+// points dataURL at it.
+func serveData(t *testing.T, version *int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(version) == 1 {
+			_, _ = w.Write([]byte(dataV1))
+		} else {
+			_, _ = w.Write([]byte(dataV2))
+		}
+	}))
+	dataURL, ratURLs = server.URL, nil
+	t.Cleanup(func() { setDataset(nil) })
+	return server
+}
+
+func TestReload(t *testing.T) {
+	version := int32(1)
+	server := serveData(t, &version)
+	defer server.Close()
+
+	summary, err := reload()
+	require.NoError(t, err)
+	assert.Equal(t, reloadSummary{Features: 2, Added: []string{"alpha", "bravo"}, Removed: []string{}}, summary)
+
+	atomic.StoreInt32(&version, 2)
+	summary, err = reload()
+	require.NoError(t, err)
+	assert.Equal(t, reloadSummary{Features: 2, Added: []string{"charlie"}, Removed: []string{"alpha"}}, summary)
+	assert.Contains(t, currentDataset().features, "charlie")
+	assert.Equal(t, 2, currentDataset().index.Len())
+
+	// A failed reload keeps the existing data.
+	server.Close()
+	_, err = reload()
+	assert.Error(t, err)
+	assert.Contains(t, currentDataset().features, "charlie")
+}
+
+func TestHandleReload(t *testing.T) {
+	version := int32(1)
+	server := serveData(t, &version)
+	defer server.Close()
+
+	reloadRequest := func(method, auth string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/v4/admin/reload", nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		handleReload(w, r)
+		return w
+	}
+
+	// Without a token configured, the endpoint is disabled.
+	adminToken = ""
+	assert.Equal(t, http.StatusForbidden, reloadRequest(http.MethodPost, "Bearer secret").Code)
+
+	adminToken = "secret"
+	defer func() { adminToken = "" }()
+	for _, auth := range []string{"", "Bearer wrong", "Bearer ", "secret", "Basic secret"} {
+		assert.Equal(t, http.StatusUnauthorized, reloadRequest(http.MethodPost, auth).Code, auth)
+	}
+	assert.Nil(t, currentDataset())
+
+	// The method is checked before the token.
+	assert.Equal(t, http.StatusMethodNotAllowed, reloadRequest(http.MethodGet, "Bearer secret").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, reloadRequest(http.MethodGet, "").Code)
+	assert.Nil(t, currentDataset())
+
+	w := reloadRequest(http.MethodPost, "Bearer secret")
+	require.Equal(t, http.StatusOK, w.Code)
+	var summary reloadSummary
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &summary))
+	assert.Equal(t, 2, summary.Features)
+
+	server.Close()
+	assert.Equal(t, http.StatusBadGateway, reloadRequest(http.MethodPost, "Bearer secret").Code)
+	assert.Len(t, currentDataset().features, 2)
+}
+
+func TestSnapshotFallback(t *testing.T) {
+	version := int32(1)
+	server := serveData(t, &version)
+	snapshotDir = t.TempDir()
+	defer func() { snapshotDir = "" }()
+
+	ds, err := loadDataset(true)
+	require.NoError(t, err)
+	assert.False(t, ds.fromSnapshot)
+	assert.FileExists(t, snapshotFile(dataURL))
+
+	server.Close()
+	_, err = loadDataset(false)
+	assert.Error(t, err)
+
+	ds, err = loadDataset(true)
+	require.NoError(t, err)
+	assert.True(t, ds.fromSnapshot)
+	assert.Len(t, ds.features, 2)
+
+	// Without a snapshot, the load fails.
+	dataURL = server.URL + "/other"
+	_, err = loadDataset(true)
+	assert.Error(t, err)
+}
+
+func TestDuplicateRATs(t *testing.T) {
+	version := int32(1)
+	server := serveData(t, &version)
+	defer server.Close()
+	rat := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`
+name: AIRSHOW
+type: RAT
+geometry:
+- upper: FL65
+  lower: SFC
+  boundary:
+  - circle:
+      radius: 5 nm
+      centre: 505007N 0001750W
+`))
+	}))
+	defer rat.Close()
+	defer func() { ratURLs = nil }()
+
+	ratURLs = []string{rat.URL + "/a.yaml"}
+	ds, err := loadDataset(false)
+	require.NoError(t, err)
+	assert.Contains(t, ds.features, "airshow-0")
+
+	ratURLs = []string{rat.URL + "/a.yaml", rat.URL + "/b.yaml"}
+	_, err = loadDataset(false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "airshow-0")
+	assert.Contains(t, err.Error(), rat.URL+"/b.yaml")
+}
+
+func TestServicesURL(t *testing.T) {
+	version := int32(1)
+	server := serveData(t, &version)
+	defer server.Close()
+	services := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`
+airspace:
+- name: ALPHA LARS
+  id: alpha-lars
+  type: OTHER
+  localtype: LARS
+  geometry:
+  - upper: FL95
+    lower: SFC
+    boundary:
+    - circle:
+        radius: 30 nm
+        centre: 520000N 0010000W
+service:
+- callsign: ALPHA RADAR
+  frequency: 123.300
+  controls: [alpha-lars]
+`))
+	}))
+	defer services.Close()
+	defer func() { servicesURLs = nil }()
+
+	servicesURLs = []string{services.URL + "/lars.yaml"}
+	ds, err := loadDataset(false)
+	require.NoError(t, err)
+	require.Len(t, ds.sources, 2)
+	assert.Equal(t, services.URL+"/lars.yaml", ds.sources[1].URL)
+	found := ds.index.Services(orb.Point{-1.2, 52.2}, airspace.Altitude{Unit: airspace.Feet, Reference: airspace.RefSFC}, 1852, nil)
+	require.NotEmpty(t, found)
+	assert.Equal(t, "ALPHA RADAR", found[0].Callsign)
+	assert.Equal(t, airspace.ServiceLARS, found[0].Type)
+
+	// The same IDs can't be loaded twice.
+	servicesURLs = []string{services.URL + "/lars.yaml", services.URL + "/again.yaml"}
+	_, err = loadDataset(false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "alpha-lars")
+}
+
+func TestSchedulesURL(t *testing.T) {
+	version := int32(1)
+	server := serveData(t, &version)
+	defer server.Close()
+	schedules := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("alpha: MON-FRI 0800-1700\ngone: HJ\n"))
+	}))
+	defer schedules.Close()
+	defer func() { schedulesURL = "" }()
+
+	schedulesURL = schedules.URL + "/schedules.yaml"
+	ds, err := loadDataset(false)
+	require.NoError(t, err)
+	require.Len(t, ds.sources, 2)
+	assert.Equal(t, schedulesURL, ds.sources[1].URL)
+	alpha := ds.features["alpha"].Geometry[0]
+	assert.Equal(t, airspace.ActivationHours, alpha.Activation.Kind)
+	wednesday := time.Date(2024, 6, 19, 0, 0, 0, 0, time.UTC)
+	assert.True(t, alpha.ActiveAt(wednesday.Add(12*time.Hour)))
+	assert.False(t, alpha.ActiveAt(wednesday.Add(20*time.Hour)))
+	assert.Equal(t, airspace.ActivationH24, ds.features["bravo"].Geometry[0].Activation.Kind)
+
+	schedules.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("alpha: SOMETIMES\n"))
+	})
+	_, err = loadDataset(false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "alpha")
+}
+
+func TestStatus(t *testing.T) {
+	setDataset(nil)
+	recordAttempt(assert.AnError)
+	st := currentStatus(time.Now())
+	assert.False(t, st.Loaded)
+	assert.Equal(t, assert.AnError.Error(), st.LastError)
+
+	w := httptest.NewRecorder()
+	handleRequestAll(w, httptest.NewRequest(http.MethodGet, "/v4/airspace/all", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	fetched := time.Now().Add(-time.Hour)
+	ds := newDataset(nil)
+	ds.fetched, ds.fromSnapshot = fetched, true
+	setDataset(ds)
+	defer setDataset(nil)
+	recordAttempt(nil)
+
+	st = currentStatus(fetched.Add(2 * time.Hour))
+	assert.True(t, st.Loaded)
+	assert.True(t, st.FromSnapshot)
+	assert.Equal(t, 7200.0, st.AgeSeconds)
+	assert.Empty(t, st.LastError)
+
+	w = httptest.NewRecorder()
+	handleStatus(w, httptest.NewRequest(http.MethodGet, "/v4/status", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"FromSnapshot":true`)
+}
+
+func TestProbes(t *testing.T) {
+	setDataset(nil)
+
+	w := httptest.NewRecorder()
+	handleHealthz(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	handleReadyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	setDataset(newDataset(nil))
+	defer setDataset(nil)
+	w = httptest.NewRecorder()
+	handleReadyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("release:\n  airac_date: '2024-08-08T00:00:00Z'\n" + dataV1))
+	}))
+	defer server.Close()
+	dataURL, ratURLs = server.URL, nil
+	defer setDataset(nil)
+
+	_, err := reload()
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	handleVersion(w, httptest.NewRequest(http.MethodGet, "/v4/airspace/version", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var v version
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &v))
+	assert.Equal(t, server.URL, v.URL)
+	assert.Len(t, v.SHA256, 64)
+	assert.WithinDuration(t, time.Now(), v.Fetched, time.Minute)
+	assert.False(t, v.FromSnapshot)
+	assert.Equal(t, 2, v.Features)
+	assert.Equal(t, 2, v.Volumes)
+	require.NotNil(t, v.Release)
+	assert.Equal(t, "2024-08-08T00:00:00Z", v.Release.AIRACDate)
+	assert.Len(t, v.Sources, 1)
+}
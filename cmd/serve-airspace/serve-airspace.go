@@ -1,64 +1,151 @@
 package main
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
-	"github.com/paulmach/orb"
-
 	airspace "github.com/paulcager/gb-airspace"
 	"github.com/paulcager/go-http-middleware"
+	"github.com/paulmach/orb"
 	flag "github.com/spf13/pflag"
 )
 
 const (
 	apiVersion = "v4"
+
+	// Defaults for /v4/airspace/nearby.
+	defaultNearbyRadius = "10 nm"
+	defaultNearbyCount  = 10
+
+	// How far ahead /v4/airspace/frequencies looks for airspace to call before entering.
+	defaultServiceRadius = "5 nm"
+
+	// Where snapshots are kept unless --snapshot-dir says otherwise. It must survive restarts, so
+	// the Dockerfile declares it as a volume.
+	defaultSnapshotDir = "/var/lib/gb-airspace"
 )
 
 var (
-	port     string
-	dataURL  string
-	features map[string]airspace.Feature
+	port           string
+	dataURL        string
+	ratURLs        []string
+	servicesURLs   []string
+	schedulesURL   string
+	reloadInterval time.Duration
+	snapshotDir    string
+	adminToken     string
 )
 
 func main() {
 	flag.StringVarP(&port, "port", "p", ":9092", "Port to listen on")
 	flag.StringVarP(&dataURL, "airspace-url", "u", "https://raw.githubusercontent.com/ahsparrow/airspace/master/airspace.yaml", "airspace.yaml URL")
+	flag.StringArrayVar(&ratURLs, "rat-url", nil, "RAT YAML URL, optionally followed by \",start,end\" RFC 3339 validity times (repeatable)")
+	flag.StringArrayVar(&servicesURLs, "services-url", nil, "YAML URL of LARS and FIS coverage, with their services, to add to the airspace (repeatable)")
+	flag.StringVar(&schedulesURL, "schedules-url", "", "YAML URL of activation schedules, such as \"MON-FRI 0800-1700\", keyed by feature or volume ID")
+	flag.DurationVar(&reloadInterval, "reload-interval", 24*time.Hour, "How often to reload the airspace data (0 to disable)")
+	flag.StringVar(&snapshotDir, "snapshot-dir", defaultSnapshotDir, "Directory for snapshots of the last good data, used if it can't be fetched at startup (empty to disable)")
+	flag.StringVar(&adminToken, "admin-token", "", "Bearer token required by the /v4/admin/ endpoints, which are disabled without one (or set $ADMIN_TOKEN)")
 	flag.Parse()
 
+	if adminToken == "" {
+		adminToken = os.Getenv("ADMIN_TOKEN")
+	}
+
 	if !strings.HasPrefix(port, ":") {
 		port = ":" + port
 	}
 
-	featureList, err := airspace.Load(dataURL)
+	initialLoad()
+	go reloadPeriodically(reloadInterval)
+	go reloadOnSignal()
+
+	server := makeHTTPServer(port)
+	log.Fatal(server.ListenAndServe())
+}
+
+func reloadPeriodically(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	for range time.Tick(interval) {
+		_, _ = reload()
+	}
+}
+
+func reloadOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		log.Println("Received SIGHUP, reloading")
+		_, _ = reload()
+	}
+}
+
+// loadRAT loads the RATs from a --rat-url flag of the form "url" or "url,start,end". Either
+// time may be empty, leaving that end of the validity window open.
+func loadRAT(flagValue string, allowSnapshot bool) ([]airspace.Feature, source, error) {
+	url, window, err := parseRATFlag(flagValue)
 	if err != nil {
-		panic(err)
+		return nil, source{}, err
 	}
 
-	features = make(map[string]airspace.Feature, len(featureList))
-	for _, f := range featureList {
-		if _, ok := features[f.ID]; ok {
-			log.Printf("Duplicate feature ID %q. Lookups will be undefined", f.ID)
-		}
-		features[f.ID] = f
+	src, err := fetch(url, allowSnapshot)
+	if err != nil {
+		return nil, source{}, fmt.Errorf("loading RAT %q: %w", url, err)
+	}
+	rats, err := airspace.DecodeRAT(src.data)
+	if err != nil {
+		return nil, source{}, fmt.Errorf("loading RAT %q: %w", url, err)
+	}
+	if window != nil {
+		airspace.SetValidity(rats, *window)
 	}
 
-	out, _ := os.Create("/tmp/pc1.txt")
-	for i, f := range features {
-		for j, v := range f.Geometry {
-			fmt.Fprintln(out, i, j, "<", v.ID, ">", v.Type, v.Sequence, v.Circle.Radius == 0, v.Lower, v.Upper)
-		}
+	for _, f := range rats {
+		log.Printf("Loaded RAT %q (%s)", f.Name, f.ID)
 	}
-	out.Close()
+	return rats, src, nil
+}
 
-	server := makeHTTPServer(port)
-	log.Fatal(server.ListenAndServe())
+// parseRATFlag splits a --rat-url flag into its URL and validity window, if it has one. The
+// times follow the last two commas, so a URL that contains a comma must be followed by them,
+// even if both are empty (e.g. "url,,").
+func parseRATFlag(flagValue string) (string, *airspace.TimeWindow, error) {
+	end := strings.LastIndexByte(flagValue, ',')
+	if end < 0 {
+		return flagValue, nil, nil
+	}
+	start := strings.LastIndexByte(flagValue[:end], ',')
+	if start < 0 {
+		return "", nil, fmt.Errorf("invalid --rat-url %q: expected url or url,start,end", flagValue)
+	}
+
+	var window airspace.TimeWindow
+	for _, p := range []struct {
+		str string
+		t   *time.Time
+	}{
+		{flagValue[start+1 : end], &window.Start},
+		{flagValue[end+1:], &window.End},
+	} {
+		if str := strings.TrimSpace(p.str); str != "" {
+			var err error
+			if *p.t, err = time.Parse(time.RFC3339, str); err != nil {
+				return "", nil, fmt.Errorf("invalid --rat-url %q: %w", flagValue, err)
+			}
+		}
+	}
+	return flagValue[:start], &window, nil
 }
 
 func makeHTTPServer(listenPort string) *http.Server {
@@ -68,10 +155,42 @@ func makeHTTPServer(listenPort string) *http.Server {
 		"/"+apiVersion+"/airspace/all",
 		middleware.MakeLoggingHandler(http.HandlerFunc(handleRequestAll)))
 
+	http.Handle(
+		"/"+apiVersion+"/airspace/all.geojson",
+		middleware.MakeLoggingHandler(http.HandlerFunc(handleRequestAllGeoJSON)))
+
+	http.Handle(
+		"/"+apiVersion+"/airspace/version",
+		middleware.MakeLoggingHandler(http.HandlerFunc(handleVersion)))
+
+	http.Handle(
+		"/"+apiVersion+"/airspace/bbox",
+		middleware.MakeLoggingHandler(http.HandlerFunc(handleBBox)))
+
+	http.Handle(
+		"/"+apiVersion+"/airspace/nearby",
+		middleware.MakeLoggingHandler(http.HandlerFunc(handleNearby)))
+
+	http.Handle(
+		"/"+apiVersion+"/airspace/frequencies",
+		middleware.MakeLoggingHandler(http.HandlerFunc(handleFrequencies)))
+
 	http.Handle(
 		"/"+apiVersion+"/airspace/",
 		middleware.MakeLoggingHandler(http.HandlerFunc(handle)))
 
+	http.Handle(
+		"/"+apiVersion+"/admin/reload",
+		middleware.MakeLoggingHandler(http.HandlerFunc(handleReload)))
+
+	http.Handle(
+		"/"+apiVersion+"/status",
+		middleware.MakeLoggingHandler(http.HandlerFunc(handleStatus)))
+
+	// Probes are called frequently, so are not logged.
+	http.HandleFunc("/healthz", handleHealthz)
+	http.HandleFunc("/readyz", handleReadyz)
+
 	log.Println("Starting HTTP server on " + listenPort)
 
 	s := &http.Server{
@@ -91,33 +210,194 @@ func handle(w http.ResponseWriter, r *http.Request) {
 	name := strings.TrimSpace(values.Get("name"))
 
 	if name != "" {
-		handleNamedRequest(w, r, name)
+		handleNamedRequest(w, r, name, strings.TrimSpace(values.Get("time")), strings.TrimSpace(values.Get("policy")))
 		return
 	}
 
 	if latLon != "" {
-		handleLatlonRequest(w, r, latLon)
+		handleLatlonRequest(w, r, latLon, strings.TrimSpace(values.Get("alt")), strings.TrimSpace(values.Get("qnh")), strings.TrimSpace(values.Get("time")), strings.TrimSpace(values.Get("policy")))
 		return
 	}
 
 	http.Error(w, "Invalid request", http.StatusBadRequest)
 }
 
-func handleRequestAll(w http.ResponseWriter, _ *http.Request) {
+func handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorisedAdmin(w, r) {
+		return
+	}
+
+	summary, err := reload()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Reload failed: %s", err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		log.Println("handleReload:", err)
+	}
+}
+
+// authorisedAdmin reports whether the request's Authorization header is "Bearer " followed by
+// the admin token, writing an error response if it is not.
+func authorisedAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if adminToken == "" {
+		http.Error(w, "Admin endpoints are disabled: no --admin-token has been set", http.StatusForbidden)
+		return false
+	}
+	auth := r.Header.Get("Authorization")
+	token := strings.TrimPrefix(auth, "Bearer ")
+	if token == auth || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+func handleStatus(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(currentStatus(time.Now())); err != nil {
+		log.Println("handleStatus:", err)
+	}
+}
+
+// handleHealthz reports that the server is running, whether or not it has any data.
+func handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintln(w, "ok")
+}
+
+// handleReadyz reports whether the server has data loaded and so can answer queries.
+func handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	if currentDataset() == nil {
+		http.Error(w, "not ready: airspace data has not been loaded", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintln(w, "ok")
+}
+
+func handleVersion(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ds := loadedDataset(w)
+	if ds == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ds.version()); err != nil {
+		log.Println("handleVersion:", err)
+	}
+}
+
+// loadedDataset returns the current dataset or, if none has been loaded yet, writes an error
+// response and returns nil.
+func loadedDataset(w http.ResponseWriter) *dataset {
+	ds := currentDataset()
+	if ds == nil {
+		http.Error(w, "Airspace data has not been loaded yet", http.StatusServiceUnavailable)
+	}
+	return ds
+}
+
+func handleRequestAll(w http.ResponseWriter, r *http.Request) {
+	timeStr := r.URL.Query().Get("time")
+	at, err := parseTime(timeStr)
+	if err != nil {
+		handleError(w, r, timeStr, err)
+		return
+	}
+
+	policyStr := r.URL.Query().Get("policy")
+	policy, err := parsePolicy(policyStr)
+	if err != nil {
+		handleError(w, r, policyStr, err)
+		return
+	}
+
+	ds := loadedDataset(w)
+	if ds == nil {
+		return
+	}
+
+	features := make(map[string]airspace.Feature)
+	for _, f := range classifyFeatures(activeFeatures(ds.featureList, at), policy) {
+		features[f.ID] = f
+	}
+
 	w.Header().Add("Content-Type", "application/json")
 	encoder := json.NewEncoder(w)
 	encoder.SetEscapeHTML(false)
-	err := encoder.Encode(features)
+	err = encoder.Encode(features)
 	if err != nil {
 		log.Println("handleRequestAll:", err)
 		http.Error(w, fmt.Sprintf("JSON encoding error: %s", err), http.StatusInternalServerError)
 	}
 }
 
-func handleNamedRequest(w http.ResponseWriter, r *http.Request, id string) {
+func handleRequestAllGeoJSON(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
+	timeStr := r.URL.Query().Get("time")
+	at, err := parseTime(timeStr)
+	if err != nil {
+		handleError(w, r, timeStr, err)
+		return
+	}
+
+	policyStr := r.URL.Query().Get("policy")
+	policy, err := parsePolicy(policyStr)
+	if err != nil {
+		handleError(w, r, policyStr, err)
+		return
+	}
+
+	ds := loadedDataset(w)
+	if ds == nil {
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/geo+json")
+	if err := airspace.ToGeoJSON(classifyFeatures(activeFeatures(ds.featureList, at), policy), w); err != nil {
+		log.Println("handleRequestAllGeoJSON:", err)
+		http.Error(w, fmt.Sprintf("JSON encoding error: %s", err), http.StatusInternalServerError)
+	}
+}
+
+func handleNamedRequest(w http.ResponseWriter, r *http.Request, id string, timeStr string, policyStr string) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	at, err := parseTime(timeStr)
+	if err != nil {
+		handleError(w, r, timeStr, err)
+		return
+	}
 
-	f, ok := features[id]
+	policy, err := parsePolicy(policyStr)
+	if err != nil {
+		handleError(w, r, policyStr, err)
+		return
+	}
+
+	ds := loadedDataset(w)
+	if ds == nil {
+		return
+	}
+
+	f, ok := ds.features[id]
+	if ok {
+		// A feature that is not active, such as an expired RAT, is not found.
+		active := classifyFeatures(activeFeatures([]airspace.Feature{f}, at), policy)
+		if ok = len(active) == 1; ok {
+			f = active[0]
+		}
+	}
 	if !ok {
 		log.Printf("Did not find feature %q\n", id)
 		http.NotFound(w, r)
@@ -127,29 +407,57 @@ func handleNamedRequest(w http.ResponseWriter, r *http.Request, id string) {
 	w.Header().Set("Content-Type", "application/json")
 	encoder := json.NewEncoder(w)
 	encoder.SetEscapeHTML(false)
-	err := encoder.Encode(f)
+	err = encoder.Encode(f)
 	if err != nil {
 		log.Println("handleNamedRequest("+id+"):", err)
 		http.Error(w, fmt.Sprintf("JSON encoding error: %s", err), http.StatusInternalServerError)
 	}
 }
 
-func handleLatlonRequest(w http.ResponseWriter, r *http.Request, latLonStr string) {
-	parts := strings.Split(latLonStr, ",")
-	if len(parts) != 2 {
-		handleError(w, r, latLonStr, nil)
+func handleLatlonRequest(w http.ResponseWriter, r *http.Request, latLonStr string, altStr string, qnhStr string, timeStr string, policyStr string) {
+	point, err := airspace.ParseLatLng(latLonStr)
+	if err != nil {
+		handleError(w, r, latLonStr, err)
+		return
+	}
+
+	at, err := parseTime(timeStr)
+	if err != nil {
+		handleError(w, r, timeStr, err)
+		return
+	}
+
+	policy, err := parsePolicy(policyStr)
+	if err != nil {
+		handleError(w, r, policyStr, err)
 		return
 	}
 
-	lat, err1 := strconv.ParseFloat(parts[0], 64)
-	lon, err2 := strconv.ParseFloat(parts[1], 64)
-	if err1 != nil || err2 != nil {
-		handleError(w, r, latLonStr, nil)
+	ds := loadedDataset(w)
+	if ds == nil {
 		return
 	}
 
-	point := orb.Point{lon, lat}
-	enclosingVolumes := airspace.EnclosingVolumes(point, features)
+	index := ds.index
+	var enclosingVolumes []airspace.Volume
+	if altStr != "" {
+		alt, datum, err := parseAltitude(altStr)
+		if err != nil {
+			handleError(w, r, altStr, err)
+			return
+		}
+		conditions := airspace.StandardConditions
+		if qnhStr != "" {
+			if conditions.QNH, err = strconv.ParseFloat(qnhStr, 64); err != nil {
+				handleError(w, r, qnhStr, err)
+				return
+			}
+		}
+		enclosingVolumes = index.QueryAtWith(point, alt, datum, conditions)
+	} else {
+		enclosingVolumes = index.Query(point)
+	}
+	enclosingVolumes = classify(activeVolumes(enclosingVolumes, at), policy)
 
 	w.Header().Add("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(enclosingVolumes); err != nil {
@@ -158,6 +466,319 @@ func handleLatlonRequest(w http.ResponseWriter, r *http.Request, latLonStr strin
 	}
 }
 
+// handleNearby returns the volumes nearest to `latlon`, nearest first, for proximity warnings.
+// At most `n` volumes are returned, and only those within `radius` (which may have units, such
+// as "5 km") or enclosing the point. Vertical separations are from `alt`, or the surface.
+func handleNearby(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	values := r.URL.Query()
+	latLonStr := strings.TrimSpace(values.Get("latlon"))
+	point, err := airspace.ParseLatLng(latLonStr)
+	if err != nil {
+		handleError(w, r, latLonStr, err)
+		return
+	}
+
+	radiusStr := values.Get("radius")
+	if radiusStr == "" {
+		radiusStr = defaultNearbyRadius
+	}
+	radius, err := airspace.ParseDistance(radiusStr)
+	if err != nil {
+		handleError(w, r, radiusStr, err)
+		return
+	}
+
+	n := defaultNearbyCount
+	if nStr := values.Get("n"); nStr != "" {
+		if n, err = strconv.Atoi(nStr); err != nil || n <= 0 {
+			handleError(w, r, nStr, err)
+			return
+		}
+	}
+
+	altStr := values.Get("alt")
+	altitude, err := parseAltitudeOrSurface(altStr)
+	if err != nil {
+		handleError(w, r, altStr, err)
+		return
+	}
+
+	timeStr := values.Get("time")
+	at, err := parseTime(timeStr)
+	if err != nil {
+		handleError(w, r, timeStr, err)
+		return
+	}
+
+	policyStr := values.Get("policy")
+	policy, err := parsePolicy(policyStr)
+	if err != nil {
+		handleError(w, r, policyStr, err)
+		return
+	}
+
+	ds := loadedDataset(w)
+	if ds == nil {
+		return
+	}
+
+	found := ds.index.Nearest(point, altitude, n, func(v airspace.Volume) bool { return v.ActiveAt(at) })
+	nearby := make([]airspace.Proximity, 0, len(found))
+	for _, p := range found {
+		if p.Inside || p.Distance <= radius {
+			if policy != nil {
+				p.Volume = policy.Classify(p.Volume)
+			}
+			nearby = append(nearby, p)
+		}
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(nearby); err != nil {
+		log.Printf("Failed to write response: %s", err)
+	}
+}
+
+// handleFrequencies returns the air traffic services a pilot at `latlon` and `alt` (or the
+// surface) should call, most relevant first: those controlling the airspace they are in, those
+// controlling airspace within `radius`, any LARS, and the FIS.
+func handleFrequencies(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	values := r.URL.Query()
+	latLonStr := strings.TrimSpace(values.Get("latlon"))
+	point, err := airspace.ParseLatLng(latLonStr)
+	if err != nil {
+		handleError(w, r, latLonStr, err)
+		return
+	}
+
+	radiusStr := values.Get("radius")
+	if radiusStr == "" {
+		radiusStr = defaultServiceRadius
+	}
+	radius, err := airspace.ParseDistance(radiusStr)
+	if err != nil {
+		handleError(w, r, radiusStr, err)
+		return
+	}
+
+	altStr := values.Get("alt")
+	altitude, err := parseAltitudeOrSurface(altStr)
+	if err != nil {
+		handleError(w, r, altStr, err)
+		return
+	}
+
+	timeStr := values.Get("time")
+	at, err := parseTime(timeStr)
+	if err != nil {
+		handleError(w, r, timeStr, err)
+		return
+	}
+
+	ds := loadedDataset(w)
+	if ds == nil {
+		return
+	}
+
+	services := ds.index.Services(point, altitude, radius, func(v airspace.Volume) bool { return v.ActiveAt(at) })
+	w.Header().Add("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(services); err != nil {
+		log.Printf("Failed to write response: %s", err)
+	}
+}
+
+// handleBBox returns the volumes intersecting the box given by `minlat`, `minlon`, `maxlat` and
+// `maxlon`, such as a map's viewport. Like point queries, it accepts `alt`, `qnh` and `time`,
+// and `type` may list the volume types wanted, separated by commas.
+func handleBBox(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	values := r.URL.Query()
+	var bound orb.Bound
+	for _, p := range []struct {
+		name string
+		v    *float64
+		max  float64
+	}{
+		{"minlat", &bound.Min[1], 90},
+		{"minlon", &bound.Min[0], 180},
+		{"maxlat", &bound.Max[1], 90},
+		{"maxlon", &bound.Max[0], 180},
+	} {
+		str := values.Get(p.name)
+		f, err := strconv.ParseFloat(str, 64)
+		// NaN would pass the range and ordering checks.
+		if err != nil || math.IsNaN(f) || math.Abs(f) > p.max {
+			handleError(w, r, p.name+"="+str, err)
+			return
+		}
+		*p.v = f
+	}
+	if bound.Min.Lat() > bound.Max.Lat() || bound.Min.Lon() > bound.Max.Lon() {
+		handleError(w, r, r.URL.RawQuery, fmt.Errorf("minimum is greater than maximum"))
+		return
+	}
+
+	var types map[string]bool
+	if typeStr := values.Get("type"); typeStr != "" {
+		types = make(map[string]bool)
+		for _, t := range strings.Split(typeStr, ",") {
+			types[strings.ToUpper(strings.TrimSpace(t))] = true
+		}
+	}
+
+	timeStr := values.Get("time")
+	at, err := parseTime(timeStr)
+	if err != nil {
+		handleError(w, r, timeStr, err)
+		return
+	}
+
+	policyStr := values.Get("policy")
+	policy, err := parsePolicy(policyStr)
+	if err != nil {
+		handleError(w, r, policyStr, err)
+		return
+	}
+
+	altStr := values.Get("alt")
+	var (
+		alt   float64
+		datum airspace.Datum
+	)
+	conditions := airspace.StandardConditions
+	if altStr != "" {
+		if alt, datum, err = parseAltitude(altStr); err != nil {
+			handleError(w, r, altStr, err)
+			return
+		}
+		if qnhStr := values.Get("qnh"); qnhStr != "" {
+			if conditions.QNH, err = strconv.ParseFloat(qnhStr, 64); err != nil {
+				handleError(w, r, qnhStr, err)
+				return
+			}
+		}
+	}
+
+	ds := loadedDataset(w)
+	if ds == nil {
+		return
+	}
+
+	volumes := make([]airspace.Volume, 0)
+	for _, v := range ds.index.VolumesInBound(bound) {
+		if types != nil && !types[strings.ToUpper(v.Type)] {
+			continue
+		}
+		if altStr != "" && !v.ContainsAltitudeWith(alt, datum, conditions) {
+			continue
+		}
+		if v.ActiveAt(at) {
+			volumes = append(volumes, v)
+		}
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(classify(volumes, policy)); err != nil {
+		log.Printf("Failed to write response: %s", err)
+	}
+}
+
+// parseTime parses an RFC 3339 time= parameter, which defaults to now.
+func parseTime(timeStr string) (time.Time, error) {
+	if timeStr == "" {
+		return time.Now(), nil
+	}
+	return time.Parse(time.RFC3339, timeStr)
+}
+
+// activeVolumes removes volumes, such as expired RATs or danger areas outside their published
+// hours, that are not active at time `t`.
+func activeVolumes(volumes []airspace.Volume, t time.Time) []airspace.Volume {
+	active := volumes[:0]
+	for _, v := range volumes {
+		if v.ActiveAt(t) {
+			active = append(active, v)
+		}
+	}
+	return active
+}
+
+// activeFeatures returns the features with only their volumes that are active at time `t`,
+// omitting any features that are left with none.
+func activeFeatures(features []airspace.Feature, t time.Time) []airspace.Feature {
+	active := make([]airspace.Feature, 0, len(features))
+	for _, f := range features {
+		if len(f.Geometry) != 0 {
+			// Copy the volumes, which belong to the dataset.
+			f.Geometry = activeVolumes(append([]airspace.Volume(nil), f.Geometry...), t)
+			if len(f.Geometry) == 0 {
+				continue
+			}
+		}
+		active = append(active, f)
+	}
+	return active
+}
+
+// parsePolicy returns the preset named by a policy= parameter, or nil if there is none, in which
+// case volumes are left as classified when they were decoded.
+func parsePolicy(policyStr string) (*airspace.Policy, error) {
+	if policyStr == "" {
+		return nil, nil
+	}
+	return airspace.LookupPolicy(policyStr)
+}
+
+// classify reclassifies the volumes, in place, using `policy` (if not nil).
+func classify(volumes []airspace.Volume, policy *airspace.Policy) []airspace.Volume {
+	if policy != nil {
+		for i := range volumes {
+			volumes[i] = policy.Classify(volumes[i])
+		}
+	}
+	return volumes
+}
+
+// classifyFeatures reclassifies the features' volumes, in place, using `policy` (if not nil).
+// The volumes must be copies, such as those returned by activeFeatures.
+func classifyFeatures(features []airspace.Feature, policy *airspace.Policy) []airspace.Feature {
+	for i := range features {
+		classify(features[i].Geometry, policy)
+	}
+	return features
+}
+
+// parseAltitudeOrSurface parses an alt= parameter as an Altitude, which is the surface if the
+// parameter is empty.
+func parseAltitudeOrSurface(altStr string) (airspace.Altitude, error) {
+	if altStr == "" {
+		return airspace.Altitude{Unit: airspace.Feet, Reference: airspace.RefSFC}, nil
+	}
+	alt, datum, err := parseAltitude(altStr)
+	if err != nil {
+		return airspace.Altitude{}, err
+	}
+	altitude := airspace.Altitude{Value: alt, Unit: airspace.Feet, Reference: airspace.RefAMSL}
+	if datum == airspace.PressureAltitude {
+		altitude.Reference = airspace.RefFL
+	}
+	return altitude, nil
+}
+
+// parseAltitude parses an altitude query parameter, which is either feet AMSL (e.g. "2500")
+// or a flight level (e.g. "FL65").
+func parseAltitude(altStr string) (float64, airspace.Datum, error) {
+	upper := strings.ToUpper(altStr)
+	if strings.HasPrefix(upper, "FL") {
+		fl, err := strconv.ParseFloat(upper[2:], 64)
+		return fl * 100, airspace.PressureAltitude, err
+	}
+	alt, err := strconv.ParseFloat(altStr, 64)
+	return alt, airspace.AMSL, err
+}
+
 func handleError(w http.ResponseWriter, _ *http.Request, str string, err error) {
 	var s string
 	if err != nil {
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	airspace "github.com/paulcager/gb-airspace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNearby(t *testing.T) {
+	features, err := airspace.Decode([]byte(dataV1))
+	require.NoError(t, err)
+	setDataset(newDataset(features))
+	defer setDataset(nil)
+
+	nearby := func(query string) []airspace.Proximity {
+		w := httptest.NewRecorder()
+		handleNearby(w, httptest.NewRequest(http.MethodGet, "/v4/airspace/nearby?"+query, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+		var found []airspace.Proximity
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &found))
+		return found
+	}
+
+	found := nearby("latlon=52.1,-1")
+	require.Len(t, found, 1)
+	assert.Equal(t, "alpha", found[0].Volume.ID)
+	assert.InDelta(t, 11120-2*1852, found[0].Distance, 20)
+	assert.InDelta(t, 180, found[0].Bearing, 0.5)
+	assert.Equal(t, 0.0, found[0].VerticalSeparation)
+
+	found = nearby("latlon=52.1,-1&radius=200km&alt=FL35")
+	require.Len(t, found, 2)
+	assert.Equal(t, "bravo", found[1].Volume.ID)
+	assert.Less(t, found[1].VerticalSeparation, 0.0)
+
+	assert.Len(t, nearby("latlon=52.1,-1&radius=200km&n=1"), 1)
+	assert.Empty(t, nearby("latlon=50,-1"))
+
+	for _, query := range []string{"", "latlon=x", "latlon=52,-1&radius=far", "latlon=52,-1&n=0", "latlon=52,-1&time=today",
+		"latlon=nan,nan", "latlon=52,-Inf", "latlon=52,-1&radius=NaN", "latlon=52,-1&radius=-5nm", "latlon=52,-1&radius=inf"} {
+		w := httptest.NewRecorder()
+		handleNearby(w, httptest.NewRequest(http.MethodGet, "/v4/airspace/nearby?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestBBox(t *testing.T) {
+	features, err := airspace.Decode([]byte(dataV1))
+	require.NoError(t, err)
+	setDataset(newDataset(features))
+	defer setDataset(nil)
+
+	bbox := func(query string) []string {
+		w := httptest.NewRecorder()
+		handleBBox(w, httptest.NewRequest(http.MethodGet, "/v4/airspace/bbox?"+query, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+		var volumes []airspace.Volume
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &volumes))
+		ids := []string{}
+		for _, v := range volumes {
+			ids = append(ids, v.ID)
+		}
+		return ids
+	}
+
+	const all = "minlat=51&minlon=-2&maxlat=54&maxlon=0"
+	assert.Equal(t, []string{"alpha"}, bbox("minlat=51.9&minlon=-1.1&maxlat=52.1&maxlon=-0.9"))
+	assert.Equal(t, []string{"alpha", "bravo"}, bbox(all))
+	assert.Equal(t, []string{"alpha", "bravo"}, bbox(all+"&type=ctr,d"))
+	assert.Empty(t, bbox(all+"&type=CTR"))
+	assert.Equal(t, []string{"alpha", "bravo"}, bbox(all+"&alt=1000"))
+	assert.Empty(t, bbox(all+"&alt=FL30&qnh=1013"))
+	assert.Empty(t, bbox("minlat=50&minlon=-2&maxlat=51&maxlon=0"))
+
+	for _, query := range []string{"", "minlat=51&minlon=-2&maxlat=54", "minlat=x&minlon=-2&maxlat=54&maxlon=0",
+		"minlat=54&minlon=-2&maxlat=51&maxlon=0", "minlat=51&minlon=-200&maxlat=54&maxlon=0", all + "&alt=high",
+		"minlat=NaN&minlon=-2&maxlat=54&maxlon=0", "minlat=51&minlon=-2&maxlat=54&maxlon=nan", "minlat=51&minlon=-Inf&maxlat=54&maxlon=0"} {
+		w := httptest.NewRecorder()
+		handleBBox(w, httptest.NewRequest(http.MethodGet, "/v4/airspace/bbox?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestPolicyParameter(t *testing.T) {
+	features, err := airspace.Decode([]byte(dataV1))
+	require.NoError(t, err)
+	setDataset(newDataset(features))
+	defer setDataset(nil)
+
+	query := func(query string) []airspace.Volume {
+		w := httptest.NewRecorder()
+		handle(w, httptest.NewRequest(http.MethodGet, "/v4/airspace?"+query, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var volumes []airspace.Volume
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &volumes))
+		require.Len(t, volumes, 1)
+		return volumes
+	}
+
+	// Danger areas are a danger by default, but need permission for a drone.
+	v := query("latlon=52,-1")[0]
+	assert.False(t, v.ClearanceRequired)
+	assert.True(t, v.Danger)
+	v = query("latlon=52,-1&policy=drone")[0]
+	assert.True(t, v.ClearanceRequired)
+	assert.False(t, v.Danger)
+
+	// The whole data set, and single features, are reclassified too.
+	w := httptest.NewRecorder()
+	handleRequestAll(w, httptest.NewRequest(http.MethodGet, "/v4/airspace/all?policy=drone", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var all map[string]airspace.Feature
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &all))
+	assert.True(t, all["alpha"].Geometry[0].ClearanceRequired)
+
+	w = httptest.NewRecorder()
+	handleRequestAllGeoJSON(w, httptest.NewRequest(http.MethodGet, "/v4/airspace/all.geojson?policy=drone", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"ClearanceRequired":true`)
+	assert.NotContains(t, w.Body.String(), `"ClearanceRequired":false`)
+
+	w = httptest.NewRecorder()
+	handle(w, httptest.NewRequest(http.MethodGet, "/v4/airspace?name=alpha&policy=drone", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var f airspace.Feature
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &f))
+	assert.True(t, f.Geometry[0].ClearanceRequired)
+
+	// Reclassifying a response leaves the data set alone.
+	assert.False(t, query("latlon=52,-1")[0].ClearanceRequired)
+
+	for _, tt := range []struct {
+		handler http.HandlerFunc
+		url     string
+	}{
+		{handle, "/v4/airspace?latlon=52,-1&policy=balloon"},
+		{handle, "/v4/airspace?name=alpha&policy=balloon"},
+		{handleBBox, "/v4/airspace/bbox?minlat=51&minlon=-2&maxlat=54&maxlon=0&policy=balloon"},
+		{handleRequestAll, "/v4/airspace/all?policy=balloon"},
+		{handleRequestAllGeoJSON, "/v4/airspace/all.geojson?policy=balloon"},
+	} {
+		w := httptest.NewRecorder()
+		tt.handler(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, tt.url)
+		assert.Contains(t, w.Body.String(), "balloon", tt.url)
+	}
+}
+
+func TestExpiredRATs(t *testing.T) {
+	features, err := airspace.Decode([]byte(dataV1))
+	require.NoError(t, err)
+	rats, err := airspace.DecodeRAT([]byte(`
+name: AIRSHOW
+type: RAT
+geometry:
+- upper: FL65
+  lower: SFC
+  boundary:
+  - circle:
+      radius: 5 nm
+      centre: 505007N 0001750W
+`))
+	require.NoError(t, err)
+	start := time.Date(2024, 8, 24, 11, 0, 0, 0, time.UTC)
+	airspace.SetValidity(rats, airspace.TimeWindow{Start: start, End: start.Add(6 * time.Hour)})
+	setDataset(newDataset(airspace.Merge(features, rats)))
+	defer setDataset(nil)
+
+	get := func(handler http.HandlerFunc, url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w
+	}
+
+	w := get(handleRequestAll, "/v4/airspace/all")
+	require.Equal(t, http.StatusOK, w.Code)
+	var all map[string]airspace.Feature
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &all))
+	assert.Len(t, all, 2)
+	assert.NotContains(t, all, "airshow-0")
+
+	w = get(handleRequestAll, "/v4/airspace/all?time=2024-08-24T12:00:00Z")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &all))
+	assert.Contains(t, all, "airshow-0")
+
+	w = get(handleRequestAllGeoJSON, "/v4/airspace/all.geojson")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "airshow-0")
+	w = get(handleRequestAllGeoJSON, "/v4/airspace/all.geojson?time=2024-08-24T12:00:00Z")
+	assert.Contains(t, w.Body.String(), "airshow-0")
+
+	assert.Equal(t, http.StatusNotFound, get(handle, "/v4/airspace/?name=airshow-0").Code)
+	assert.Equal(t, http.StatusOK, get(handle, "/v4/airspace/?name=airshow-0&time=2024-08-24T12:00:00Z").Code)
+	assert.Equal(t, http.StatusOK, get(handle, "/v4/airspace/?name=alpha").Code)
+	assert.Equal(t, http.StatusBadRequest, get(handle, "/v4/airspace/?name=alpha&time=today").Code)
+}
+
+func TestFrequencies(t *testing.T) {
+	features, err := airspace.Decode([]byte(`
+airspace:
+- name: SLEAP
+  id: sleap-atz
+  type: ATZ
+  geometry:
+  - upper: 2000 ft SFC
+    lower: SFC
+    boundary:
+    - circle:
+        radius: 2 nm
+        centre: 524000N 0024000W
+service:
+- callsign: SLEAP RADIO
+  frequency: 118.650
+  controls: [sleap-atz]
+`))
+	require.NoError(t, err)
+	setDataset(newDataset(features))
+	defer setDataset(nil)
+
+	frequencies := func(query string) []airspace.Service {
+		w := httptest.NewRecorder()
+		handleFrequencies(w, httptest.NewRequest(http.MethodGet, "/v4/airspace/frequencies?"+query, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var found []airspace.Service
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &found))
+		return found
+	}
+
+	found := frequencies("latlon=52.68,-2.67")
+	require.Len(t, found, 2)
+	assert.Equal(t, "SLEAP RADIO", found[0].Callsign)
+	assert.Equal(t, "118.650", found[0].Frequency)
+	assert.True(t, found[0].Inside)
+	assert.Equal(t, "LONDON INFORMATION", found[1].Callsign)
+
+	// About 6 nm away, the ATZ is only included with a larger radius.
+	assert.Len(t, frequencies("latlon=52.55,-2.67"), 1)
+	assert.Len(t, frequencies("latlon=52.55,-2.67&radius=7nm"), 2)
+	assert.Len(t, frequencies("latlon=52.68,-2.67&alt=FL35"), 1)
+
+	for _, query := range []string{"", "latlon=x", "latlon=52,-1&radius=far", "latlon=52,-1&alt=high", "latlon=52,-1&time=today"} {
+		w := httptest.NewRecorder()
+		handleFrequencies(w, httptest.NewRequest(http.MethodGet, "/v4/airspace/frequencies?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestParseRATFlag(t *testing.T) {
+	start := time.Date(2024, 8, 24, 11, 0, 0, 0, time.UTC)
+	end := start.Add(6 * time.Hour)
+	tests := []struct {
+		flag   string
+		url    string
+		window *airspace.TimeWindow
+	}{
+		{"https://example.com/rat.yaml", "https://example.com/rat.yaml", nil},
+		{"https://example.com/rat.yaml,2024-08-24T11:00:00Z,2024-08-24T17:00:00Z", "https://example.com/rat.yaml", &airspace.TimeWindow{Start: start, End: end}},
+		{"https://example.com/rat.yaml,,2024-08-24T17:00:00Z", "https://example.com/rat.yaml", &airspace.TimeWindow{End: end}},
+		{"https://example.com/rat?a,b,,", "https://example.com/rat?a,b", &airspace.TimeWindow{}},
+		{"https://example.com/rat?a,b,2024-08-24T11:00:00Z,", "https://example.com/rat?a,b", &airspace.TimeWindow{Start: start}},
+	}
+	for _, tt := range tests {
+		url, window, err := parseRATFlag(tt.flag)
+		require.NoError(t, err, tt.flag)
+		assert.Equal(t, tt.url, url, tt.flag)
+		assert.Equal(t, tt.window, window, tt.flag)
+	}
+
+	for _, flag := range []string{"https://example.com/rat.yaml,2024-08-24T11:00:00Z", "https://example.com/rat?a,b,c"} {
+		_, _, err := parseRATFlag(flag)
+		assert.Error(t, err, flag)
+	}
+}
@@ -0,0 +1,51 @@
+// write-openair converts airspace.yaml into an OpenAir file ready to load onto a flight instrument.
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	airspace "github.com/paulcager/gb-airspace"
+	"github.com/paulcager/gb-airspace/openair"
+	flag "github.com/spf13/pflag"
+)
+
+var (
+	dataURL    string
+	outputFile string
+)
+
+func main() {
+	flag.StringVarP(&dataURL, "airspace-url", "u", "https://raw.githubusercontent.com/ahsparrow/airspace/master/airspace.yaml", "airspace.yaml URL or file name")
+	flag.StringVarP(&outputFile, "output", "o", "-", "OpenAir file to write, or - for standard output")
+	flag.Parse()
+
+	var (
+		features []airspace.Feature
+		err      error
+	)
+	if strings.HasPrefix(dataURL, "http://") || strings.HasPrefix(dataURL, "https://") {
+		features, err = airspace.Load(dataURL)
+	} else {
+		features, err = airspace.LoadFile(dataURL)
+	}
+	if err != nil {
+		log.Fatalf("Could not load %s: %s", dataURL, err)
+	}
+
+	var out io.WriteCloser = os.Stdout
+	if outputFile != "-" {
+		if out, err = os.Create(outputFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := openair.Write(out, features); err != nil {
+		log.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,208 @@
+package airspace
+
+import (
+	"math"
+
+	"github.com/paulmach/orb"
+)
+
+// EarthModel is the shape of the Earth used to measure distances, such as from the centre of
+// a circular volume. The zero EarthModel is WGS84.
+type EarthModel int
+
+const (
+	// WGS84 measures geodesics on the WGS84 ellipsoid, using Vincenty's formulae. It agrees
+	// with published radii to well under a metre.
+	WGS84 EarthModel = iota
+	// Sphere uses the haversine formula on a sphere of the Earth's mean radius. It is faster,
+	// but distances in the UK can be out by about 0.3%, or 10m across an ATZ.
+	Sphere
+)
+
+const (
+	meanEarthRadius = 6371008.8 // Metres.
+
+	wgs84SemiMajorAxis = 6378137.0
+	wgs84Flattening    = 1 / 298.257223563
+)
+
+func (m EarthModel) String() string {
+	switch m {
+	case WGS84:
+		return "WGS84"
+	case Sphere:
+		return "Sphere"
+	default:
+		return "EarthModel(?)"
+	}
+}
+
+// Distance returns the shortest distance, in metres, between `a` and `b`.
+func (m EarthModel) Distance(a, b orb.Point) float64 {
+	if m == WGS84 {
+		if d, ok := vincentyDistance(a, b); ok {
+			return d
+		}
+	}
+	return haversineDistance(a, b)
+}
+
+// Destination returns the point `distance` metres from `start` along the geodesic with the
+// initial bearing `bearing` degrees.
+func (m EarthModel) Destination(start orb.Point, bearing, distance float64) orb.Point {
+	if m == WGS84 {
+		return vincentyDestination(start, bearing, distance)
+	}
+	return sphericalDestination(start, bearing, distance)
+}
+
+// localScale returns the number of metres in a degree of longitude, and in a degree of
+// latitude, near `p`. Distances over a few tens of kilometres can then be measured on a plane
+// that agrees with the model.
+func (m EarthModel) localScale(p orb.Point) (float64, float64) {
+	lat := toRadians(p.Lat())
+	if m == WGS84 {
+		e2 := wgs84Flattening * (2 - wgs84Flattening)
+		w := math.Sqrt(1 - e2*math.Sin(lat)*math.Sin(lat))
+		meridional := wgs84SemiMajorAxis * (1 - e2) / (w * w * w)
+		primeVertical := wgs84SemiMajorAxis / w
+		return toRadians(1) * primeVertical * math.Cos(lat), toRadians(1) * meridional
+	}
+	return toRadians(1) * meanEarthRadius * math.Cos(lat), toRadians(1) * meanEarthRadius
+}
+
+// Contains reports whether `p` is within the circle, using `model` to measure the distance
+// from its centre.
+func (c Circle) Contains(p orb.Point, model EarthModel) bool {
+	return model.Distance(c.Centre, p) <= c.Radius
+}
+
+func haversineDistance(a, b orb.Point) float64 {
+	lat1, lat2 := toRadians(a.Lat()), toRadians(b.Lat())
+	dLat := lat2 - lat1
+	dLon := toRadians(b.Lon() - a.Lon())
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * meanEarthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func sphericalDestination(start orb.Point, bearing, distance float64) orb.Point {
+	// Convert distance to angular distance (radians) by dividing by Earth's radius
+	angularDistance := distance / meanEarthRadius
+
+	// Convert all angles to radians for trigonometric calculations
+	bearingRadians := toRadians(bearing)
+	lat1 := toRadians(start.Lat())
+	lon1 := toRadians(start.Lon())
+
+	// Calculate destination latitude using spherical trigonometry
+	// Formula: lat2 = asin(sin(lat1)*cos(d) + cos(lat1)*sin(d)*cos(bearing))
+	sinLat2 := math.Sin(lat1)*math.Cos(angularDistance) +
+		math.Cos(lat1)*math.Sin(angularDistance)*math.Cos(bearingRadians)
+	lat2 := math.Asin(sinLat2)
+
+	// Calculate destination longitude
+	// Formula: lon2 = lon1 + atan2(sin(bearing)*sin(d)*cos(lat1), cos(d) - sin(lat1)*sin(lat2))
+	x := math.Cos(angularDistance) - math.Sin(lat1)*sinLat2
+	y := math.Sin(bearingRadians) * math.Sin(angularDistance) * math.Cos(lat1)
+	lon2 := lon1 + math.Atan2(y, x)
+
+	// Convert back to degrees and return as Point{lon, lat}
+	return orb.Point{toDegrees(lon2), toDegrees(lat2)}
+}
+
+// vincentyDestination solves the direct geodesic problem on the WGS84 ellipsoid.
+func vincentyDestination(start orb.Point, bearing, distance float64) orb.Point {
+	const (
+		a = wgs84SemiMajorAxis
+		f = wgs84Flattening
+		b = a * (1 - f)
+	)
+	sinAlpha1, cosAlpha1 := math.Sincos(toRadians(bearing))
+	tanU1 := (1 - f) * math.Tan(toRadians(start.Lat()))
+	cosU1 := 1 / math.Sqrt(1+tanU1*tanU1)
+	sinU1 := tanU1 * cosU1
+
+	sigma1 := math.Atan2(tanU1, cosAlpha1)
+	sinAlpha := cosU1 * sinAlpha1
+	cos2Alpha := 1 - sinAlpha*sinAlpha
+	uSq := cos2Alpha * (a*a - b*b) / (b * b)
+	bigA := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+	bigB := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+
+	sigma := distance / (b * bigA)
+	var sinSigma, cosSigma, cos2SigmaM float64
+	for i := 0; i < 100; i++ {
+		cos2SigmaM = math.Cos(2*sigma1 + sigma)
+		sinSigma, cosSigma = math.Sincos(sigma)
+		deltaSigma := bigB * sinSigma * (cos2SigmaM + bigB/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+			bigB/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+		prev := sigma
+		sigma = distance/(b*bigA) + deltaSigma
+		if math.Abs(sigma-prev) <= 1e-12 {
+			break
+		}
+	}
+	sinSigma, cosSigma = math.Sincos(sigma)
+	cos2SigmaM = math.Cos(2*sigma1 + sigma)
+
+	x := sinU1*sinSigma - cosU1*cosSigma*cosAlpha1
+	lat2 := math.Atan2(sinU1*cosSigma+cosU1*sinSigma*cosAlpha1, (1-f)*math.Hypot(sinAlpha, x))
+	lambda := math.Atan2(sinSigma*sinAlpha1, cosU1*cosSigma-sinU1*sinSigma*cosAlpha1)
+	c := f / 16 * cos2Alpha * (4 + f*(4-3*cos2Alpha))
+	l := lambda - (1-c)*f*sinAlpha*(sigma+c*sinSigma*(cos2SigmaM+c*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+
+	return orb.Point{start.Lon() + toDegrees(l), toDegrees(lat2)}
+}
+
+// vincentyDistance solves the inverse geodesic problem on the WGS84 ellipsoid. It returns
+// false in the rare cases, for nearly antipodal points, where the iteration fails to converge.
+//
+// See https://en.wikipedia.org/wiki/Vincenty%27s_formulae
+func vincentyDistance(p1, p2 orb.Point) (float64, bool) {
+	const (
+		a = wgs84SemiMajorAxis
+		f = wgs84Flattening
+		b = a * (1 - f)
+	)
+	if p1.Equal(p2) {
+		return 0, true
+	}
+
+	l := toRadians(p2.Lon() - p1.Lon())
+	u1 := math.Atan((1 - f) * math.Tan(toRadians(p1.Lat())))
+	u2 := math.Atan((1 - f) * math.Tan(toRadians(p2.Lat())))
+	sinU1, cosU1 := math.Sincos(u1)
+	sinU2, cosU2 := math.Sincos(u2)
+
+	lambda := l
+	for i := 0; i < 100; i++ {
+		sinLambda, cosLambda := math.Sincos(lambda)
+		sinSigma := math.Hypot(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+		if sinSigma == 0 {
+			return 0, true // Coincident points.
+		}
+		cosSigma := sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma := math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cos2Alpha := 1 - sinAlpha*sinAlpha
+		cos2SigmaM := 0.0 // Both points on the equator.
+		if cos2Alpha != 0 {
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cos2Alpha
+		}
+		c := f / 16 * cos2Alpha * (4 + f*(4-3*cos2Alpha))
+
+		prev := lambda
+		lambda = l + (1-c)*f*sinAlpha*(sigma+c*sinSigma*(cos2SigmaM+c*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-prev) > 1e-12 {
+			continue
+		}
+
+		uSq := cos2Alpha * (a*a - b*b) / (b * b)
+		bigA := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+		bigB := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+		deltaSigma := bigB * sinSigma * (cos2SigmaM + bigB/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+			bigB/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+		return b * bigA * (sigma - deltaSigma), true
+	}
+	return 0, false
+}
@@ -0,0 +1,90 @@
+package airspace
+
+import (
+	"math"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+	"github.com/paulmach/orb/project"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEarthModelDistance(t *testing.T) {
+	// Flinders Peak to Buninyong, the worked example in Vincenty's paper.
+	flinders := orb.Point{144 + 25.0/60 + 29.52440/3600, -(37 + 57.0/60 + 3.72030/3600)}
+	buninyong := orb.Point{143 + 55.0/60 + 35.38390/3600, -(37 + 39.0/60 + 10.15610/3600)}
+	assert.InDelta(t, 54972.271, WGS84.Distance(flinders, buninyong), 0.001)
+	assert.InDelta(t, 54972.271, Sphere.Distance(flinders, buninyong), 0.005*54972)
+
+	// One degree of latitude at the equator and at the pole, from the ellipsoid's radii of curvature.
+	assert.InDelta(t, 110574.4, WGS84.Distance(orb.Point{0, -0.5}, orb.Point{0, 0.5}), 0.1)
+	assert.InDelta(t, 111693.9, WGS84.Distance(orb.Point{0, 89}, orb.Point{0, 90}), 0.1)
+
+	assert.Equal(t, 0.0, WGS84.Distance(flinders, flinders))
+	// Nearly antipodal points, where Vincenty's method does not converge.
+	assert.InDelta(t, math.Pi*meanEarthRadius, WGS84.Distance(orb.Point{0, 0}, orb.Point{179.7, 0}), 0.005*math.Pi*meanEarthRadius)
+}
+
+func TestEarthModelDestination(t *testing.T) {
+	start := orb.Point{-2.88, 52.24}
+	for _, model := range []EarthModel{WGS84, Sphere} {
+		for bearing := 0.0; bearing < 360; bearing += 45 {
+			p := model.Destination(start, bearing, 3704)
+			assert.InDelta(t, 3704, model.Distance(start, p), 0.001, "%v, bearing %v", model, bearing)
+		}
+	}
+}
+
+func TestCircleContainsATZ(t *testing.T) {
+	// Shobdon ATZ: a 2 nm circle centred on 521431N 0025252W. Points due north and east of the
+	// centre are computed independently from the ellipsoid's radii of curvature.
+	atz := Circle{Radius: 2 * 1852, Centre: orb.Point{-(2 + 52.0/60 + 52.0/3600), 52 + 14.0/60 + 31.0/3600}}
+	lat := toRadians(atz.Centre.Lat())
+	e2 := wgs84Flattening * (2 - wgs84Flattening)
+	w := math.Sqrt(1 - e2*math.Sin(lat)*math.Sin(lat))
+	meridional := wgs84SemiMajorAxis * (1 - e2) / (w * w * w)
+	primeVertical := wgs84SemiMajorAxis / w
+
+	edge := func(bearing, scale float64) orb.Point {
+		d := atz.Radius * scale
+		switch bearing {
+		case 0:
+			return orb.Point{atz.Centre.Lon(), atz.Centre.Lat() + toDegrees(d/meridional)}
+		case 90:
+			return orb.Point{atz.Centre.Lon() + toDegrees(d/(primeVertical*math.Cos(lat))), atz.Centre.Lat()}
+		case 180:
+			return orb.Point{atz.Centre.Lon(), atz.Centre.Lat() - toDegrees(d/meridional)}
+		default:
+			return orb.Point{atz.Centre.Lon() - toDegrees(d/(primeVertical*math.Cos(lat))), atz.Centre.Lat()}
+		}
+	}
+
+	for _, bearing := range []float64{0, 90, 180, 270} {
+		inside, outside := edge(bearing, 0.999), edge(bearing, 1.001)
+		assert.True(t, atz.Contains(inside, WGS84), "bearing %v", bearing)
+		assert.False(t, atz.Contains(outside, WGS84), "bearing %v", bearing)
+		assert.True(t, isEnclosedBy(inside, Volume{Circle: atz}, WGS84), "bearing %v", bearing)
+		assert.False(t, isEnclosedBy(outside, Volume{Circle: atz}, WGS84), "bearing %v", bearing)
+
+		// Measuring in Mercator metres, as isEnclosedBy used to, stretches distances by about
+		// 1/cos(lat) and so puts points well inside the ATZ outside it.
+		mercator := planar.Distance(project.Point(inside, project.WGS84.ToMercator), project.Point(atz.Centre, project.WGS84.ToMercator))
+		assert.Greater(t, mercator, atz.Radius, "bearing %v", bearing)
+	}
+
+	// The sphere is accurate to about 0.3%, so is right about points 1% from the edge.
+	for _, bearing := range []float64{0, 90, 180, 270} {
+		assert.True(t, atz.Contains(edge(bearing, 0.99), Sphere), "bearing %v", bearing)
+		assert.False(t, atz.Contains(edge(bearing, 1.01), Sphere), "bearing %v", bearing)
+	}
+}
+
+func TestEarthModelLocalScale(t *testing.T) {
+	p := orb.Point{-2.88, 52.24}
+	for _, model := range []EarthModel{WGS84, Sphere} {
+		scaleX, scaleY := model.localScale(p)
+		assert.InDelta(t, model.Distance(p, orb.Point{p.Lon() + 0.01, p.Lat()}), 0.01*scaleX, 0.01, "%v", model)
+		assert.InDelta(t, model.Distance(p, orb.Point{p.Lon(), p.Lat() + 0.01}), 0.01*scaleY, 0.01, "%v", model)
+	}
+}
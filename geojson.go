@@ -0,0 +1,86 @@
+package airspace
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+// ToGeoJSON writes the volumes of each feature as a GeoJSON FeatureCollection. Each volume
+// becomes a Polygon feature (circles are approximated by polygons), with the volume's
+// attributes as properties. Volumes with no horizontal shape are omitted.
+func ToGeoJSON(features []Feature, w io.Writer) error {
+	fc := geojson.NewFeatureCollection()
+	for _, f := range features {
+		for _, v := range f.Geometry {
+			ring := volumeRing(v)
+			if len(ring) == 0 {
+				continue
+			}
+			gf := geojson.NewFeature(orb.Polygon{ring})
+			gf.Properties = volumeProperties(f, v)
+			fc.Append(gf)
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(fc)
+}
+
+// volumeProperties returns the GeoJSON properties describing a volume. The limits are given
+// both as published (e.g. "FL65") and in feet AMSL under standard conditions, so that clients
+// can filter on them; unlimited upper limits have null feet. Optional attributes, such as the
+// Frequency, are only included if known.
+func volumeProperties(f Feature, v Volume) geojson.Properties {
+	props := geojson.Properties{
+		"ID":                v.ID,
+		"FeatureID":         f.ID,
+		"Name":              v.Name,
+		"Type":              v.Type,
+		"Class":             v.Class,
+		"Sequence":          v.Sequence,
+		"Lower":             v.Lower.String(),
+		"Upper":             v.Upper.String(),
+		"LowerFeet":         jsonFeet(v.Lower),
+		"UpperFeet":         jsonFeet(v.Upper),
+		"ClearanceRequired": v.ClearanceRequired,
+		"Danger":            v.Danger,
+	}
+	for k, s := range map[string]string{
+		"LocalType":   v.LocalType,
+		"ControlType": v.ControlType,
+		"Callsign":    v.Callsign,
+		"Frequency":   v.Frequency,
+		"Remarks":     v.Remarks,
+	} {
+		if s != "" {
+			props[k] = s
+		}
+	}
+	return props
+}
+
+// volumeRing returns the volume's horizontal shape as a closed, anticlockwise ring (as RFC 7946
+// requires of exterior rings), or nil if it has none.
+func volumeRing(v Volume) orb.Ring {
+	var ring orb.Ring
+	switch {
+	case len(v.Polygon) > 0:
+		ring = v.Polygon.Clone()
+	case v.Circle.Radius != 0:
+		ring = circleToRing(v.Circle, circleStep)
+	default:
+		return nil
+	}
+
+	if !ring.Closed() {
+		ring = append(ring, ring[0])
+	}
+	if ring.Orientation() == orb.CW {
+		ring.Reverse()
+	}
+	return ring
+}
@@ -0,0 +1,63 @@
+package airspace
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToGeoJSON(t *testing.T) {
+	features, err := Decode([]byte(data))
+	require.NoError(t, err)
+	features = append(features, Feature{ID: "test-atz", Geometry: []Volume{{
+		ID: "test-atz", Name: "TEST ATZ", Type: "ATZ", Class: "G",
+		Lower:             Altitude{Unit: Feet, Reference: RefSFC},
+		Upper:             Altitude{Unit: Feet, Reference: RefUNL},
+		ClearanceRequired: true,
+		Frequency:         "123.500",
+		Circle:            Circle{Radius: 2 * 1852, Centre: orb.Point{-1.5, 53.0}},
+	}}})
+
+	var b bytes.Buffer
+	require.NoError(t, ToGeoJSON(features, &b))
+
+	fc, err := geojson.UnmarshalFeatureCollection(b.Bytes())
+	require.NoError(t, err)
+	require.Len(t, fc.Features, 4)
+
+	for _, f := range fc.Features {
+		polygon, ok := f.Geometry.(orb.Polygon)
+		require.True(t, ok, "Every volume should be a Polygon")
+		require.Len(t, polygon, 1)
+		assert.True(t, polygon[0].Closed(), "Rings should be closed")
+		assert.Equal(t, orb.CCW, polygon[0].Orientation(), "Exterior rings should be anticlockwise")
+	}
+
+	props := fc.Features[0].Properties
+	assert.Equal(t, "aberdeen-cta", props.MustString("ID"))
+	assert.Equal(t, "aberdeen-cta", props.MustString("FeatureID"))
+	assert.Equal(t, "ABERDEEN CTA", props.MustString("Name"))
+	assert.Equal(t, "CTA", props.MustString("Type"))
+	assert.Equal(t, "D", props.MustString("Class"))
+	assert.Equal(t, 1, props.MustInt("Sequence"))
+	assert.Equal(t, "1500 ft", props.MustString("Lower"))
+	assert.Equal(t, "FL115", props.MustString("Upper"))
+	assert.Equal(t, 1500.0, props.MustFloat64("LowerFeet"))
+	assert.Equal(t, 11500.0, props.MustFloat64("UpperFeet"))
+	assert.True(t, props.MustBool("ClearanceRequired"))
+	assert.False(t, props.MustBool("Danger"))
+
+	circle := fc.Features[3]
+	assert.Equal(t, "UNL", circle.Properties.MustString("Upper"))
+	assert.Equal(t, "123.500", circle.Properties.MustString("Frequency"))
+	assert.Nil(t, circle.Properties["Callsign"], "Unknown attributes are omitted")
+	assert.Nil(t, circle.Properties["UpperFeet"])
+	assert.Len(t, circle.Geometry.(orb.Polygon)[0], 37)
+	for _, p := range circle.Geometry.(orb.Polygon)[0] {
+		assert.InDelta(t, 2*1852, WGS84.Distance(p, orb.Point{-1.5, 53.0}), 1)
+	}
+}
@@ -3,11 +3,10 @@ module github.com/paulcager/gb-airspace
 go 1.15
 
 require (
-	github.com/kr/pretty v0.2.1
+	github.com/kr/pretty v0.2.1 // indirect
 	github.com/paulcager/go-http-middleware v0.0.2
 	github.com/paulmach/orb v0.1.7
 	github.com/spf13/pflag v1.0.5
 	github.com/stretchr/testify v1.6.1
 	gopkg.in/yaml.v2 v2.4.0
 )
-
@@ -0,0 +1,267 @@
+package airspace
+
+import (
+	"math"
+	"sort"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb/planar"
+)
+
+// indexNodeSize is the maximum number of children held by each node of the index's R-tree.
+const indexNodeSize = 16
+
+// Index is a spatial index over the volumes of a set of features. It is an R-tree over the
+// volumes' bounding boxes, bulk-loaded using the Sort-Tile-Recursive algorithm, so point and
+// bounding box queries only need to run the (comparatively expensive) containment test on
+// volumes that are close to the query.
+//
+// Distances, such as from the centre of a circular volume, are measured on the WGS84
+// ellipsoid unless another model is chosen with WithEarthModel.
+//
+// An Index is never modified once built, so it may be shared between goroutines.
+type Index struct {
+	volumes []Volume
+	root    *indexNode
+	model   EarthModel
+	// If not nil, the index is linear: it has no tree, and queries test every volume of these
+	// features instead. See scanIndex.
+	features map[string]Feature
+}
+
+// indexNode is a node in the R-tree. Leaf nodes have no children and refer to a single volume.
+type indexNode struct {
+	bound    orb.Bound
+	children []*indexNode
+	volume   int // Index into Index.volumes; only meaningful for leaf nodes.
+}
+
+// NewIndex builds an index over every volume of the given features. Query results are
+// returned in the order the volumes appear in `features`.
+func NewIndex(features []Feature) *Index {
+	idx := &Index{}
+	var nodes []*indexNode
+	for _, f := range features {
+		for _, v := range f.Geometry {
+			nodes = append(nodes, &indexNode{bound: volumeBound(v), volume: len(idx.volumes)})
+			idx.volumes = append(idx.volumes, v)
+		}
+	}
+
+	for len(nodes) > 1 {
+		nodes = packNodes(nodes)
+	}
+	if len(nodes) == 1 {
+		idx.root = nodes[0]
+	}
+
+	return idx
+}
+
+// scanIndex returns a linear index over every volume of the given features: it has no tree,
+// so costs nothing to build, and each query tests every volume, which suits callers that only
+// make a single query. Volumes are visited in map order, so callers should sort the results
+// (see sortByID).
+func scanIndex(features map[string]Feature) *Index {
+	if features == nil {
+		features = map[string]Feature{}
+	}
+	return &Index{features: features}
+}
+
+// sortByID sorts volumes by ID, keeping the volumes of each feature in order, so that the
+// results of queries on a scanIndex are repeatable.
+func sortByID(volumes []Volume) []Volume {
+	sort.SliceStable(volumes, func(i, j int) bool { return volumes[i].ID < volumes[j].ID })
+	return volumes
+}
+
+// WithEarthModel returns a copy of the index that measures distances using `model`. The copy
+// shares the original's tree, so is cheap to make.
+func (idx *Index) WithEarthModel(model EarthModel) *Index {
+	c := *idx
+	c.model = model
+	return &c
+}
+
+// Len returns the number of volumes in the index.
+func (idx *Index) Len() int {
+	if idx.features != nil {
+		n := 0
+		for _, f := range idx.features {
+			n += len(f.Geometry)
+		}
+		return n
+	}
+	return len(idx.volumes)
+}
+
+// Query returns every volume enclosing `point`.
+func (idx *Index) Query(point orb.Point) []Volume {
+	enclosingVolumes := make([]Volume, 0)
+	idx.candidates(orb.Bound{Min: point, Max: point}, func(v Volume) {
+		if isEnclosedBy(point, v, idx.model) {
+			enclosingVolumes = append(enclosingVolumes, v)
+		}
+	})
+	return enclosingVolumes
+}
+
+// QueryBound returns every volume whose bounding box intersects `bound`. The volume itself
+// may not intersect `bound`, so callers needing an exact answer must test the results.
+func (idx *Index) QueryBound(bound orb.Bound) []Volume {
+	volumes := make([]Volume, 0)
+	idx.candidates(bound, func(v Volume) { volumes = append(volumes, v) })
+	return volumes
+}
+
+// VolumesInBound returns every volume whose horizontal shape intersects `bound`, such as a
+// map's viewport. Arcs are tested using their flattened Polygon.
+func (idx *Index) VolumesInBound(bound orb.Bound) []Volume {
+	volumes := make([]Volume, 0)
+	idx.candidates(bound, func(v Volume) {
+		if intersectsBound(v, bound, idx.model) {
+			volumes = append(volumes, v)
+		}
+	})
+	return volumes
+}
+
+// VolumesInBound returns every volume whose horizontal shape intersects `bound`. It tests
+// every volume; see Index.VolumesInBound.
+func VolumesInBound(bound orb.Bound, features map[string]Feature) []Volume {
+	return sortByID(scanIndex(features).VolumesInBound(bound))
+}
+
+// intersectsBound reports whether the volume's horizontal shape and `bound` overlap.
+func intersectsBound(vol Volume, bound orb.Bound, model EarthModel) bool {
+	if vol.Circle.Radius != 0 {
+		// Clamping the centre to the bound gives the bound's nearest point to it.
+		c := vol.Circle.Centre
+		nearest := orb.Point{
+			math.Max(bound.Min.X(), math.Min(bound.Max.X(), c.X())),
+			math.Max(bound.Min.Y(), math.Min(bound.Max.Y(), c.Y())),
+		}
+		if model.Distance(c, nearest) <= vol.Circle.Radius {
+			return true
+		}
+	}
+
+	ring := vol.Polygon
+	if len(ring) == 0 {
+		return false
+	}
+	for _, p := range ring {
+		if bound.Contains(p) {
+			return true
+		}
+	}
+	// The bound may be entirely inside the volume...
+	if planar.RingContains(ring, bound.Center()) {
+		return true
+	}
+	// ...or the volume's edges may cross it without any vertices inside.
+	corners := []orb.Point{bound.Min, {bound.Max.X(), bound.Min.Y()}, bound.Max, {bound.Min.X(), bound.Max.Y()}}
+	for i := range ring {
+		a, b := ring[i], ring[(i+1)%len(ring)]
+		for j := range corners {
+			if _, ok := segmentIntersection(a, b, corners[j], corners[(j+1)%len(corners)]); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// candidates calls `fn` with every volume whose bounding box intersects `bound`, in the order
+// they were given to NewIndex. If the index is linear it calls `fn` with every volume.
+func (idx *Index) candidates(bound orb.Bound, fn func(Volume)) {
+	if idx.features != nil {
+		for _, f := range idx.features {
+			for _, v := range f.Geometry {
+				fn(v)
+			}
+		}
+		return
+	}
+	var found []int
+	if idx.root != nil {
+		idx.root.search(bound, func(i int) { found = append(found, i) })
+	}
+	sort.Ints(found)
+	for _, i := range found {
+		fn(idx.volumes[i])
+	}
+}
+
+func (n *indexNode) search(bound orb.Bound, fn func(int)) {
+	if !n.bound.Intersects(bound) {
+		return
+	}
+	if n.children == nil {
+		fn(n.volume)
+		return
+	}
+	for _, c := range n.children {
+		c.search(bound, fn)
+	}
+}
+
+// packNodes groups `nodes` into parent nodes of up to indexNodeSize children each, tiling them
+// into vertical slices by longitude and then packing each slice by latitude.
+func packNodes(nodes []*indexNode) []*indexNode {
+	numParents := int(math.Ceil(float64(len(nodes)) / indexNodeSize))
+	numSlices := int(math.Ceil(math.Sqrt(float64(numParents))))
+	sliceSize := numSlices * indexNodeSize
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].bound.Center().X() < nodes[j].bound.Center().X() })
+
+	parents := make([]*indexNode, 0, numParents)
+	for start := 0; start < len(nodes); start += sliceSize {
+		slice := nodes[start:minInt(start+sliceSize, len(nodes))]
+		sort.Slice(slice, func(i, j int) bool { return slice[i].bound.Center().Y() < slice[j].bound.Center().Y() })
+
+		for i := 0; i < len(slice); i += indexNodeSize {
+			children := slice[i:minInt(i+indexNodeSize, len(slice))]
+			parent := &indexNode{bound: children[0].bound, children: append([]*indexNode(nil), children...)}
+			for _, c := range children[1:] {
+				parent.bound = parent.bound.Union(c.bound)
+			}
+			parents = append(parents, parent)
+		}
+	}
+
+	return parents
+}
+
+// volumeBound returns the bounding box of a volume's horizontal shape.
+func volumeBound(vol Volume) orb.Bound {
+	var b orb.Bound
+	hasBound := false
+	if vol.Circle.Radius != 0 {
+		b = geo.NewBoundAroundPoint(vol.Circle.Centre, vol.Circle.Radius)
+		hasBound = true
+	}
+	if len(vol.Polygon) > 0 {
+		if hasBound {
+			b = b.Union(vol.Polygon.Bound())
+		} else {
+			b = vol.Polygon.Bound()
+		}
+	}
+	// Arcs may bulge slightly beyond their flattened chords.
+	for _, s := range vol.Boundary {
+		if s.Arc != nil {
+			b = b.Union(s.Arc.Bound())
+		}
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
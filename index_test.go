@@ -0,0 +1,197 @@
+package airspace
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syntheticFeatures generates `n` features scattered over the UK, alternating between circles
+// and polygons, to give the index a realistically sized data set without needing a download.
+func syntheticFeatures(n int) []Feature {
+	r := rand.New(rand.NewSource(1))
+	features := make([]Feature, 0, n)
+	for i := 0; i < n; i++ {
+		centre := orb.Point{minLon + r.Float64()*(maxLon-minLon), minLat + r.Float64()*(maxLat-minLat)}
+		radius := 1000 + r.Float64()*20000
+		vol := Volume{ID: fmt.Sprintf("synthetic-%d", i), Name: fmt.Sprintf("SYNTHETIC %d", i), Type: "CTR", Class: "D"}
+		if i%2 == 0 {
+			vol.Circle = Circle{Radius: radius, Centre: centre}
+		} else {
+			for bearing := 0.0; bearing < 360; bearing += 30 {
+				vol.Polygon = append(vol.Polygon, destinationPoint(centre, bearing, radius))
+			}
+			vol.Polygon = append(vol.Polygon, vol.Polygon[0])
+		}
+		features = append(features, Feature{ID: vol.ID, Name: vol.Name, Type: vol.Type, Class: vol.Class, Geometry: []Volume{vol}})
+	}
+	return features
+}
+
+// linearEnclosingVolumes is the brute-force equivalent of Index.Query, used to check the index.
+func linearEnclosingVolumes(point orb.Point, features []Feature) []Volume {
+	enclosingVolumes := make([]Volume, 0)
+	for _, f := range features {
+		for _, v := range f.Geometry {
+			if isEnclosedBy(point, v, WGS84) {
+				enclosingVolumes = append(enclosingVolumes, v)
+			}
+		}
+	}
+	return enclosingVolumes
+}
+
+func TestIndexQuery(t *testing.T) {
+	features, err := Decode([]byte(data))
+	require.NoError(t, err)
+	idx := NewIndex(features)
+	assert.Equal(t, 3, idx.Len())
+
+	// Inside seqno 1 of the Aberdeen CTA only.
+	vols := idx.Query(orb.Point{-2.2, 57.4})
+	require.Len(t, vols, 1)
+	assert.Equal(t, 1, vols[0].Sequence)
+
+	// Well away from Aberdeen.
+	assert.Empty(t, idx.Query(orb.Point{-0.1, 51.5}))
+}
+
+func TestIndexMatchesLinearScan(t *testing.T) {
+	features := syntheticFeatures(2000)
+	idx := NewIndex(features)
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 1000; i++ {
+		p := orb.Point{minLon + r.Float64()*(maxLon-minLon), minLat + r.Float64()*(maxLat-minLat)}
+		assert.Equal(t, linearEnclosingVolumes(p, features), idx.Query(p), "Mismatch at %v", p)
+	}
+}
+
+func TestIndexQueryBound(t *testing.T) {
+	features, err := Decode([]byte(data))
+	require.NoError(t, err)
+	idx := NewIndex(features)
+
+	assert.Len(t, idx.QueryBound(orb.Bound{Min: orb.Point{-3, 56.5}, Max: orb.Point{-1.5, 57.5}}), 3)
+	assert.Empty(t, idx.QueryBound(orb.Bound{Min: orb.Point{-1, 51}, Max: orb.Point{0, 52}}))
+}
+
+func TestVolumesInBound(t *testing.T) {
+	features := []Feature{
+		{ID: "triangle", Geometry: []Volume{{ID: "triangle", Polygon: orb.Ring{{0, 50}, {1, 50}, {0, 51}}}}},
+		{ID: "circle", Geometry: []Volume{{ID: "circle", Circle: Circle{Radius: 10000, Centre: orb.Point{0, 52}}}}},
+	}
+	idx := NewIndex(features)
+
+	tests := []struct {
+		name     string
+		bound    orb.Bound
+		expected []string
+	}{
+		{"Vertex inside", orb.Bound{Min: orb.Point{-0.1, 49.9}, Max: orb.Point{0.1, 50.1}}, []string{"triangle"}},
+		{"Inside volume", orb.Bound{Min: orb.Point{0.1, 50.1}, Max: orb.Point{0.2, 50.2}}, []string{"triangle"}},
+		{"Crossing edge", orb.Bound{Min: orb.Point{0.45, 50.45}, Max: orb.Point{0.65, 50.65}}, []string{"triangle"}},
+		{"Outside, within bounding box", orb.Bound{Min: orb.Point{0.9, 50.9}, Max: orb.Point{1, 51}}, nil},
+		{"Near circle", orb.Bound{Min: orb.Point{0.1, 52}, Max: orb.Point{0.2, 52.1}}, []string{"circle"}},
+		{"Outside circle, within bounding box", orb.Bound{Min: orb.Point{0.1, 52.07}, Max: orb.Point{0.2, 52.2}}, nil},
+		{"Everything", orb.Bound{Min: orb.Point{-1, 49}, Max: orb.Point{1, 53}}, []string{"triangle", "circle"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []string
+			for _, v := range idx.VolumesInBound(tt.bound) {
+				ids = append(ids, v.ID)
+			}
+			assert.Equal(t, tt.expected, ids)
+		})
+	}
+
+	assert.Len(t, idx.QueryBound(orb.Bound{Min: orb.Point{0.9, 50.9}, Max: orb.Point{1, 51}}), 1)
+	assert.Len(t, VolumesInBound(orb.Bound{Min: orb.Point{-1, 49}, Max: orb.Point{1, 53}}, map[string]Feature{"circle": features[1]}), 1)
+}
+
+func TestEmptyIndex(t *testing.T) {
+	idx := NewIndex(nil)
+	assert.Equal(t, 0, idx.Len())
+	assert.Empty(t, idx.Query(orb.Point{-2.2, 57.4}))
+	assert.Empty(t, idx.QueryBound(orb.Bound{Min: orb.Point{-3, 56.5}, Max: orb.Point{-1.5, 57.5}}))
+}
+
+func TestEnclosingVolumes(t *testing.T) {
+	features, err := Decode([]byte(data))
+	require.NoError(t, err)
+	featureMap := map[string]Feature{features[0].ID: features[0]}
+
+	vols := EnclosingVolumes(orb.Point{-2.2, 57.4}, featureMap)
+	require.Len(t, vols, 1)
+	assert.Equal(t, "aberdeen-cta", vols[0].ID)
+
+	assert.Empty(t, EnclosingVolumes(orb.Point{-2.2, 57.4}, nil))
+}
+
+func TestScanIndexMatchesLinearScan(t *testing.T) {
+	features := syntheticFeatures(500)
+	featureMap := make(map[string]Feature, len(features))
+	for _, f := range features {
+		featureMap[f.ID] = f
+	}
+	idx := scanIndex(featureMap)
+	assert.Equal(t, len(features), idx.Len())
+	r := rand.New(rand.NewSource(4))
+	for i := 0; i < 200; i++ {
+		p := orb.Point{minLon + r.Float64()*(maxLon-minLon), minLat + r.Float64()*(maxLat-minLat)}
+		assert.Equal(t, sortByID(linearEnclosingVolumes(p, features)), EnclosingVolumes(p, featureMap), "Mismatch at %v", p)
+		assert.ElementsMatch(t, linearEnclosingVolumes(p, features), idx.Query(p), "Mismatch at %v", p)
+	}
+}
+
+func benchmarkPoints(n int) []orb.Point {
+	r := rand.New(rand.NewSource(3))
+	points := make([]orb.Point, n)
+	for i := range points {
+		points[i] = orb.Point{minLon + r.Float64()*(maxLon-minLon), minLat + r.Float64()*(maxLat-minLat)}
+	}
+	return points
+}
+
+func BenchmarkLinearScan(b *testing.B) {
+	features := syntheticFeatures(2000)
+	points := benchmarkPoints(1024)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		linearEnclosingVolumes(points[i%len(points)], features)
+	}
+}
+
+func BenchmarkEnclosingVolumes(b *testing.B) {
+	features := make(map[string]Feature)
+	for _, f := range syntheticFeatures(2000) {
+		features[f.ID] = f
+	}
+	points := benchmarkPoints(1024)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		EnclosingVolumes(points[i%len(points)], features)
+	}
+}
+
+func BenchmarkIndexQuery(b *testing.B) {
+	idx := NewIndex(syntheticFeatures(2000))
+	points := benchmarkPoints(1024)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idx.Query(points[i%len(points)])
+	}
+}
+
+func BenchmarkNewIndex(b *testing.B) {
+	features := syntheticFeatures(2000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewIndex(features)
+	}
+}
@@ -0,0 +1,255 @@
+package airspace
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/paulmach/orb"
+)
+
+// Intersection describes one passage of a track through a volume. A track that enters the same
+// volume more than once will have one Intersection per passage.
+type Intersection struct {
+	Volume Volume
+	Entry  orb.Point
+	Exit   orb.Point
+	// Distances along the track, in metres, from its first point.
+	EntryDistance float64
+	ExitDistance  float64
+	// Interpolated from the times of the track's points. Zero if no times were supplied.
+	EntryTime time.Time
+	ExitTime  time.Time
+}
+
+// track is a LineString parameterised by `s`, where s = i + t is the point a fraction t of the
+// way along the i'th segment.
+type track struct {
+	points    orb.LineString
+	times     []time.Time
+	distances []float64 // Cumulative distance to each point.
+	model     EarthModel
+}
+
+func newTrack(points orb.LineString, times []time.Time, model EarthModel) track {
+	tr := track{points: points, times: times, distances: make([]float64, len(points)), model: model}
+	for i := 1; i < len(points); i++ {
+		tr.distances[i] = tr.distances[i-1] + model.Distance(points[i-1], points[i])
+	}
+	return tr
+}
+
+func (tr track) split(s float64) (int, float64) {
+	i := int(math.Floor(s))
+	if i >= len(tr.points)-1 {
+		i = len(tr.points) - 2
+	}
+	if i < 0 {
+		return 0, 0
+	}
+	return i, s - float64(i)
+}
+
+func (tr track) point(s float64) orb.Point {
+	if len(tr.points) == 1 {
+		return tr.points[0]
+	}
+	i, t := tr.split(s)
+	a, b := tr.points[i], tr.points[i+1]
+	return orb.Point{a[0] + t*(b[0]-a[0]), a[1] + t*(b[1]-a[1])}
+}
+
+func (tr track) distance(s float64) float64 {
+	if len(tr.points) == 1 {
+		return 0
+	}
+	i, t := tr.split(s)
+	return tr.distances[i] + t*(tr.distances[i+1]-tr.distances[i])
+}
+
+func (tr track) time(s float64) time.Time {
+	if len(tr.times) == 0 {
+		return time.Time{}
+	}
+	if len(tr.points) == 1 {
+		return tr.times[0]
+	}
+	i, t := tr.split(s)
+	return tr.times[i].Add(time.Duration(t * float64(tr.times[i+1].Sub(tr.times[i]))))
+}
+
+// IntersectLineString returns every passage of `points` through a volume, in the order they
+// occur along the track. If `times` is not empty it must hold the time of each point of the
+// track, and is used to interpolate the entry and exit times.
+func (idx *Index) IntersectLineString(points orb.LineString, times []time.Time) ([]Intersection, error) {
+	if len(times) != 0 && len(times) != len(points) {
+		return nil, fmt.Errorf("track has %d points but %d times", len(points), len(times))
+	}
+	if len(points) == 0 {
+		return nil, nil
+	}
+
+	tr := newTrack(points, times, idx.model)
+	var intersections []Intersection
+	idx.candidates(points.Bound(), func(vol Volume) {
+		for _, sp := range tr.spans(vol) {
+			intersections = append(intersections, Intersection{
+				Volume:        vol,
+				Entry:         tr.point(sp[0]),
+				Exit:          tr.point(sp[1]),
+				EntryDistance: tr.distance(sp[0]),
+				ExitDistance:  tr.distance(sp[1]),
+				EntryTime:     tr.time(sp[0]),
+				ExitTime:      tr.time(sp[1]),
+			})
+		}
+	})
+
+	sort.SliceStable(intersections, func(i, j int) bool {
+		a, b := intersections[i], intersections[j]
+		if a.EntryDistance != b.EntryDistance {
+			return a.EntryDistance < b.EntryDistance
+		}
+		return a.Volume.ID < b.Volume.ID
+	})
+	return intersections, nil
+}
+
+// IntersectLineString returns every passage of `track` through a volume. It tests every
+// volume; see Index.IntersectLineString.
+func IntersectLineString(track orb.LineString, times []time.Time, features map[string]Feature) ([]Intersection, error) {
+	return scanIndex(features).IntersectLineString(track, times)
+}
+
+// spans returns the [start, end] parameters of each part of the track inside `vol`.
+//
+// The track is split at each of its points and wherever it might cross the boundary of the
+// volume. Each piece is then either wholly inside or wholly outside the volume, so testing the
+// midpoint of each piece is enough to tell which. The boundary between an outside piece and an
+// inside one is then found by bisection, so that entry and exit points agree exactly with
+// isEnclosedBy.
+func (tr track) spans(vol Volume) [][2]float64 {
+	if len(tr.points) == 1 {
+		if isEnclosedBy(tr.points[0], vol, tr.model) {
+			return [][2]float64{{0, 0}}
+		}
+		return nil
+	}
+
+	splits := []float64{}
+	for i := 0; i < len(tr.points)-1; i++ {
+		splits = append(splits, float64(i))
+		for _, t := range boundaryCrossings(tr.points[i], tr.points[i+1], vol, tr.model) {
+			splits = append(splits, float64(i)+t)
+		}
+	}
+	splits = append(splits, float64(len(tr.points)-1))
+	sort.Float64s(splits)
+
+	var spans [][2]float64
+	var start float64
+	wasInside := false
+	prevMid := 0.0
+	for k := 0; k < len(splits)-1; k++ {
+		if splits[k+1]-splits[k] < 1e-12 {
+			continue
+		}
+		mid := (splits[k] + splits[k+1]) / 2
+		inside := isEnclosedBy(tr.point(mid), vol, tr.model)
+		switch {
+		case inside && !wasInside:
+			start = 0
+			if k > 0 {
+				start = tr.bisect(vol, prevMid, mid)
+			}
+		case !inside && wasInside:
+			spans = append(spans, [2]float64{start, tr.bisect(vol, mid, prevMid)})
+		}
+		wasInside = inside
+		prevMid = mid
+	}
+	if wasInside {
+		spans = append(spans, [2]float64{start, float64(len(tr.points) - 1)})
+	}
+
+	return spans
+}
+
+// bisect finds the point where the track crosses the boundary of `vol` between `out`, which is
+// outside the volume, and `in`, which is inside it. The returned parameter is always inside.
+func (tr track) bisect(vol Volume, out, in float64) float64 {
+	for i := 0; i < 50 && math.Abs(in-out) > 1e-10; i++ {
+		mid := (out + in) / 2
+		if isEnclosedBy(tr.point(mid), vol, tr.model) {
+			in = mid
+		} else {
+			out = mid
+		}
+	}
+	return in
+}
+
+// boundaryCrossings returns the fractions along the segment a-b at which it may cross the
+// boundary of `vol`. These only need to be approximate, as they are refined by bisection.
+func boundaryCrossings(a, b orb.Point, vol Volume, model EarthModel) []float64 {
+	var crossings []float64
+
+	if vol.Circle.Radius != 0 {
+		crossings = append(crossings, circleCrossings(a, b, vol.Circle.Centre, vol.Circle.Radius, model)...)
+	}
+	for _, s := range vol.Boundary {
+		if s.Arc != nil {
+			crossings = append(crossings, circleCrossings(a, b, s.Arc.Centre, s.Arc.Radius, model)...)
+		}
+	}
+
+	for i := 0; i < len(vol.Polygon); i++ {
+		p, q := vol.Polygon[i], vol.Polygon[(i+1)%len(vol.Polygon)]
+		if t, ok := segmentIntersection(a, b, p, q); ok {
+			crossings = append(crossings, t)
+		}
+	}
+
+	return crossings
+}
+
+// circleCrossings returns the fractions along the segment a-b at which it crosses the circle.
+func circleCrossings(a, b orb.Point, centre orb.Point, radius float64, model EarthModel) []float64 {
+	// Work in metres on a local plane centred on the circle.
+	scaleX, scaleY := model.localScale(centre)
+	ax, ay := (a.Lon()-centre.Lon())*scaleX, (a.Lat()-centre.Lat())*scaleY
+	dx, dy := (b.Lon()-a.Lon())*scaleX, (b.Lat()-a.Lat())*scaleY
+
+	// Solve |a + t.d|^2 = r^2.
+	var crossings []float64
+	qa := dx*dx + dy*dy
+	qb := 2 * (ax*dx + ay*dy)
+	qc := ax*ax + ay*ay - radius*radius
+	if disc := qb*qb - 4*qa*qc; qa > 0 && disc >= 0 {
+		sqrtDisc := math.Sqrt(disc)
+		for _, t := range []float64{(-qb - sqrtDisc) / (2 * qa), (-qb + sqrtDisc) / (2 * qa)} {
+			if t > 0 && t < 1 {
+				crossings = append(crossings, t)
+			}
+		}
+	}
+	return crossings
+}
+
+// segmentIntersection returns the fraction along a-b at which it crosses p-q, if it does.
+func segmentIntersection(a, b, p, q orb.Point) (float64, bool) {
+	r := orb.Point{b[0] - a[0], b[1] - a[1]}
+	s := orb.Point{q[0] - p[0], q[1] - p[1]}
+	denom := r[0]*s[1] - r[1]*s[0]
+	if denom == 0 {
+		return 0, false
+	}
+	ap := orb.Point{p[0] - a[0], p[1] - a[1]}
+	t := (ap[0]*s[1] - ap[1]*s[0]) / denom
+	u := (ap[0]*r[1] - ap[1]*r[0]) / denom
+	if t < 0 || t > 1 || u < 0 || u > 1 {
+		return 0, false
+	}
+	return t, true
+}
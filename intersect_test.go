@@ -0,0 +1,101 @@
+package airspace
+
+import (
+	"testing"
+	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntersectLineString(t *testing.T) {
+	features, err := Decode([]byte(data))
+	require.NoError(t, err)
+	idx := NewIndex(features)
+
+	// East to west across the northern part (seqno 1) of the Aberdeen CTA.
+	track := orb.LineString{{-1.5, 57.4}, {-3.0, 57.4}}
+	start := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	times := []time.Time{start, start.Add(100 * time.Second)}
+
+	intersections, err := idx.IntersectLineString(track, times)
+	require.NoError(t, err)
+	require.Len(t, intersections, 1)
+
+	i := intersections[0]
+	assert.Equal(t, 1, i.Volume.Sequence)
+	assert.InDelta(t, -2.008, i.Entry.Lon(), 0.01)
+	assert.InDelta(t, -2.526, i.Exit.Lon(), 0.01)
+	assert.InDelta(t, 57.4, i.Entry.Lat(), 1e-9)
+	assert.Less(t, i.EntryDistance, i.ExitDistance)
+
+	// Constant speed, so times are proportional to distance.
+	total := newTrack(track, nil, WGS84).distances[1]
+	assert.WithinDuration(t, start.Add(time.Duration(i.EntryDistance/total*100*float64(time.Second))), i.EntryTime, time.Millisecond)
+	assert.WithinDuration(t, start.Add(time.Duration(i.ExitDistance/total*100*float64(time.Second))), i.ExitTime, time.Millisecond)
+}
+
+func TestIntersectLineStringStartsInside(t *testing.T) {
+	features, err := Decode([]byte(data))
+	require.NoError(t, err)
+
+	track := orb.LineString{{-2.2, 57.4}, {-2.2, 57.45}, {-1.5, 57.45}}
+	intersections, err := NewIndex(features).IntersectLineString(track, nil)
+	require.NoError(t, err)
+	require.Len(t, intersections, 1)
+	assert.Equal(t, track[0], intersections[0].Entry)
+	assert.Equal(t, 0.0, intersections[0].EntryDistance)
+	assert.True(t, intersections[0].EntryTime.IsZero())
+	assert.Greater(t, intersections[0].ExitDistance, newTrack(track, nil, WGS84).distances[1], "Should leave on the second leg")
+}
+
+func TestIntersectLineStringCircle(t *testing.T) {
+	centre := orb.Point{-1.0, 53.0}
+	vol := Volume{ID: "atz", Circle: Circle{Radius: 2 * 1852, Centre: centre}}
+	idx := NewIndex([]Feature{{ID: "atz", Geometry: []Volume{vol}}})
+
+	// In and out twice.
+	track := orb.LineString{{-1.2, 53.0}, {-0.8, 53.0}, {-0.8, 53.001}, {-1.2, 53.001}}
+	intersections, err := idx.IntersectLineString(track, nil)
+	require.NoError(t, err)
+	require.Len(t, intersections, 2)
+
+	for _, i := range intersections {
+		assert.True(t, isEnclosedBy(i.Entry, vol, WGS84), "Entry should be inside")
+		assert.True(t, isEnclosedBy(i.Exit, vol, WGS84), "Exit should be inside")
+		assert.Less(t, i.EntryDistance, i.ExitDistance)
+	}
+	assert.Less(t, intersections[0].ExitDistance, intersections[1].EntryDistance)
+}
+
+func TestIntersectLineStringEarthModel(t *testing.T) {
+	centre := orb.Point{-1.0, 53.0}
+	vol := Volume{ID: "atz", Circle: Circle{Radius: 2 * 1852, Centre: centre}}
+	idx := NewIndex([]Feature{{ID: "atz", Geometry: []Volume{vol}}})
+	track := orb.LineString{{-1.2, 53.0}, {-0.8, 53.0}}
+
+	for _, model := range []EarthModel{WGS84, Sphere} {
+		intersections, err := idx.WithEarthModel(model).IntersectLineString(track, nil)
+		require.NoError(t, err)
+		require.Len(t, intersections, 1)
+
+		// Distances along the track and across the circle are measured by the same model.
+		i := intersections[0]
+		assert.True(t, isEnclosedBy(i.Entry, vol, model), "%v", model)
+		assert.InDelta(t, model.Distance(track[0], i.Entry), i.EntryDistance, 0.1, "%v", model)
+		assert.InDelta(t, model.Distance(track[0], i.Exit), i.ExitDistance, 0.1, "%v", model)
+		assert.InDelta(t, 2*vol.Circle.Radius, i.ExitDistance-i.EntryDistance, 0.1, "%v", model)
+	}
+}
+
+func TestIntersectLineStringErrors(t *testing.T) {
+	idx := NewIndex(nil)
+
+	_, err := idx.IntersectLineString(orb.LineString{{0, 0}, {1, 1}}, []time.Time{time.Now()})
+	assert.Error(t, err, "Times must match points")
+
+	intersections, err := idx.IntersectLineString(nil, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, intersections)
+}
@@ -0,0 +1,124 @@
+package airspace
+
+import (
+	"math"
+	"sort"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+)
+
+// nearestSearchRadius is the radius, in metres, of the first area Nearest searches. It is
+// doubled until enough volumes have been found.
+const nearestSearchRadius = 10000.0
+
+// Proximity describes how close a volume is to a point, as returned by Index.Nearest.
+type Proximity struct {
+	Volume Volume
+	// Whether the point is inside the volume's horizontal shape.
+	Inside bool
+	// The horizontal distance, in metres, to the nearest point on the volume's boundary, and
+	// the initial bearing, in degrees, to that point. When Inside, this is the way out.
+	Distance float64
+	Bearing  float64
+	// The vertical distance, in feet, from the altitude to the nearer of the volume's limits:
+	// positive if the volume is above, negative if it is below, and zero if the altitude is
+	// within its limits.
+	VerticalSeparation float64
+}
+
+// Nearest returns the `n` volumes closest to `point`, nearest first, with those the point is
+// inside (horizontally) coming before all others. Vertical separations are from `altitude`,
+// assuming StandardConditions. If `filter` is not nil, only volumes for which it returns true
+// are considered. It returns nil if the point is not a valid longitude and latitude.
+func (idx *Index) Nearest(point orb.Point, altitude Altitude, n int, filter func(Volume) bool) []Proximity {
+	if idx.Len() == 0 || n <= 0 || !validPoint(point) {
+		return nil
+	}
+
+	// Every volume with a boundary within `radius` of the point has a bounding box that
+	// intersects the search area, so once the n'th nearest is within `radius` we are done.
+	// Nothing is further away than half the Earth's circumference.
+	for radius := nearestSearchRadius; ; radius *= 2 {
+		area := boundAround(point, radius)
+		var found []Proximity
+		idx.candidates(area, func(v Volume) {
+			if filter == nil || filter(v) {
+				found = append(found, proximity(point, altitude, v, idx.model))
+			}
+		})
+		sort.SliceStable(found, func(i, j int) bool { return found[i].less(found[j]) })
+
+		everything := idx.features != nil || radius > math.Pi*meanEarthRadius ||
+			area.Contains(idx.root.bound.Min) && area.Contains(idx.root.bound.Max)
+		if len(found) >= n && found[n-1].sortKey() <= radius || everything {
+			if len(found) > n {
+				found = found[:n]
+			}
+			return found
+		}
+	}
+}
+
+// validPoint reports whether `p` is a finite longitude and latitude, within range.
+func validPoint(p orb.Point) bool {
+	return math.Abs(p.Lon()) <= 180 && math.Abs(p.Lat()) <= 90
+}
+
+// NearestVolumes returns the `n` volumes closest to `point`. It tests every volume; see
+// Index.Nearest.
+func NearestVolumes(point orb.Point, altitude Altitude, n int, filter func(Volume) bool, features map[string]Feature) []Proximity {
+	return scanIndex(features).Nearest(point, altitude, n, filter)
+}
+
+func proximity(point orb.Point, altitude Altitude, vol Volume, model EarthModel) Proximity {
+	nearest, d := nearestBoundaryPoint(point, vol, model)
+	p := Proximity{
+		Volume:   vol,
+		Inside:   isEnclosedBy(point, vol, model),
+		Distance: d,
+		Bearing:  normaliseBearing(geo.Bearing(point, nearest)),
+	}
+
+	alt := altitude.FeetAMSL(StandardConditions)
+	if lower := vol.Lower.FeetAMSL(StandardConditions); alt < lower {
+		p.VerticalSeparation = lower - alt
+	} else if upper := vol.Upper.FeetAMSL(StandardConditions); alt > upper {
+		p.VerticalSeparation = upper - alt
+	}
+	return p
+}
+
+// sortKey is the distance used to order proximities: zero for volumes the point is inside.
+func (p Proximity) sortKey() float64 {
+	if p.Inside {
+		return 0
+	}
+	return p.Distance
+}
+
+func (p Proximity) less(q Proximity) bool {
+	if p.sortKey() != q.sortKey() {
+		return p.sortKey() < q.sortKey()
+	}
+	if p.Distance != q.Distance {
+		return p.Distance < q.Distance
+	}
+	return p.Volume.ID < q.Volume.ID
+}
+
+// boundAround returns a bound containing every point within `radius` metres of `p`, under
+// either Earth model. A degree of latitude is shortest at the equator of the WGS84 ellipsoid,
+// where the meridional radius of curvature is a(1-e²).
+func boundAround(p orb.Point, radius float64) orb.Bound {
+	e2 := wgs84Flattening * (2 - wgs84Flattening)
+	dLat := toDegrees(radius / (wgs84SemiMajorAxis * (1 - e2)))
+	dLon := 180.0
+	if c := math.Cos(toRadians(math.Min(89, math.Abs(p.Lat())+dLat))); dLat < 90 {
+		dLon = math.Min(180, dLat/c)
+	}
+	return orb.Bound{
+		Min: orb.Point{math.Max(-180, p.Lon()-dLon), math.Max(-90, p.Lat()-dLat)},
+		Max: orb.Point{math.Min(180, p.Lon()+dLon), math.Min(90, p.Lat()+dLat)},
+	}
+}
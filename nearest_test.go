@@ -0,0 +1,67 @@
+package airspace
+
+import (
+	"math"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNearest(t *testing.T) {
+	circle := func(id string, lat float64, lower, upper Altitude) Feature {
+		return Feature{ID: id, Geometry: []Volume{{
+			ID:     id,
+			Circle: Circle{Radius: 2 * 1852, Centre: orb.Point{-1, lat}},
+			Lower:  lower,
+			Upper:  upper,
+		}}}
+	}
+	sfc := Altitude{Unit: Feet, Reference: RefSFC}
+	features := []Feature{
+		circle("far", 53, sfc, Altitude{Value: 2000, Unit: Feet, Reference: RefAMSL}),
+		circle("near", 52, Altitude{Value: 3000, Unit: Feet, Reference: RefAMSL}, Altitude{Value: 6500, Unit: Feet, Reference: RefFL}),
+		circle("south", 50, sfc, Altitude{Value: 2000, Unit: Feet, Reference: RefAMSL}),
+	}
+	idx := NewIndex(features)
+	point := orb.Point{-1, 52.1}
+	alt := Altitude{Value: 1000, Unit: Feet, Reference: RefAMSL}
+
+	found := idx.Nearest(point, alt, 2, nil)
+	require.Len(t, found, 2)
+	assert.Equal(t, "near", found[0].Volume.ID)
+	assert.False(t, found[0].Inside)
+	assert.InDelta(t, 11120-2*1852, found[0].Distance, 20)
+	assert.InDelta(t, 180, found[0].Bearing, 0.5)
+	assert.InDelta(t, 2000, found[0].VerticalSeparation, 0.1)
+	assert.Equal(t, "far", found[1].Volume.ID)
+	assert.InDelta(t, 0, found[1].Bearing, 0.5)
+	assert.Equal(t, 0.0, found[1].VerticalSeparation)
+
+	// Volumes well beyond the initial search radius are still found.
+	found = idx.Nearest(point, alt, 10, nil)
+	require.Len(t, found, 3)
+	assert.Equal(t, "south", found[2].Volume.ID)
+
+	found = idx.Nearest(point, Altitude{Value: 8500, Unit: Feet, Reference: RefFL}, 1, func(v Volume) bool { return v.ID != "near" })
+	require.Len(t, found, 1)
+	assert.Equal(t, "far", found[0].Volume.ID)
+	assert.Less(t, found[0].VerticalSeparation, -6000.0)
+
+	// The distance from inside a volume is the distance to its boundary.
+	found = idx.Nearest(orb.Point{-1, 52.0}, alt, 1, nil)
+	require.Len(t, found, 1)
+	assert.True(t, found[0].Inside)
+	assert.InDelta(t, 2*1852, found[0].Distance, 1)
+
+	// From the other side of the world, everything is found.
+	assert.Len(t, idx.Nearest(orb.Point{179, -52}, alt, 10, nil), 3)
+
+	for _, p := range []orb.Point{{math.NaN(), math.NaN()}, {math.Inf(-1), 52}, {-1, 91}, {181, 52}} {
+		assert.Nil(t, idx.Nearest(p, alt, 1, nil), "%v", p)
+	}
+
+	assert.Empty(t, NewIndex(nil).Nearest(point, alt, 1, nil))
+	assert.Len(t, NearestVolumes(point, alt, 1, nil, map[string]Feature{"near": features[1]}), 1)
+}
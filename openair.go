@@ -0,0 +1,299 @@
+package airspace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/paulmach/orb"
+)
+
+// openAirCoordinate matches coordinates such as "57:21:53 N 001:58:35 W", "57:21:53.5N 1:58:35W"
+// and "57:21.883 N 001:58.583 W".
+var openAirCoordinate = regexp.MustCompile(
+	`^\s*(\d+):(\d+(?:\.\d+)?)(?::(\d+(?:\.\d+)?))?\s*([NSns])\s*(\d+):(\d+(?:\.\d+)?)(?::(\d+(?:\.\d+)?))?\s*([EWew])\s*$`)
+
+// openAirClasses maps OpenAir AC records that are not ICAO classes to airspace types.
+var openAirClasses = map[string]string{
+	"R":   "R",
+	"Q":   "D",
+	"P":   "P",
+	"GP":  "P",
+	"CTR": "CTR",
+	"RMZ": "RMZ",
+	"TMZ": "TMZ",
+}
+
+// unnamedOpenAir is the name given to records without an AN record. Like other names, it is
+// made unique by the record's position in the file, e.g. "unnamed-3".
+const unnamedOpenAir = "UNNAMED"
+
+// openAirParser holds the state of the record currently being parsed.
+type openAirParser struct {
+	features  []Feature
+	feat      *Feature
+	bb        boundaryBuilder
+	centre    orb.Point
+	clockwise bool
+}
+
+// DecodeOpenAir parses airspace in the OpenAir format, as supported by most flight instruments.
+// It understands the AC, AN, AY, AF, AG, AL, AH, V X=, V D=, DP, DA, DB and DC records; other
+// records are ignored. Each AC record becomes a Feature with a single Volume, which extends
+// from the surface or without limit if its AL or AH record is missing.
+//
+// See http://www.winpilot.com/UsersGuide/UserAirspace.asp for a description of the format.
+func DecodeOpenAir(r io.Reader) ([]Feature, error) {
+	p := &openAirParser{}
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		if err := p.parseLine(scanner.Text()); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	p.finish()
+	return p.features, nil
+}
+
+func (p *openAirParser) parseLine(line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "*") {
+		return nil
+	}
+
+	record, arg := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		record, arg = line[:i], strings.TrimSpace(line[i+1:])
+	}
+	record = strings.ToUpper(record)
+
+	if record == "AC" {
+		p.start(strings.ToUpper(arg))
+		return nil
+	}
+	if p.feat == nil {
+		return fmt.Errorf("%s record before first AC record", record)
+	}
+	vol := &p.feat.Geometry[0]
+
+	switch record {
+	case "AN":
+		p.feat.Name = arg
+		vol.Name = arg
+	case "AY":
+		p.feat.Type = strings.ToUpper(arg)
+		vol.Type = p.feat.Type
+	case "AF":
+		p.feat.Frequency = arg
+		vol.Frequency = arg
+	case "AG":
+		p.feat.Callsign = arg
+		vol.Callsign = arg
+	case "AL":
+		var err error
+		if vol.Lower, err = parseOpenAirHeight(arg); err != nil {
+			return err
+		}
+	case "AH":
+		var err error
+		if vol.Upper, err = parseOpenAirHeight(arg); err != nil {
+			return err
+		}
+	case "V":
+		return p.parseVariable(arg)
+	case "DP":
+		pt, err := parseOpenAirCoordinate(arg)
+		if err != nil {
+			return err
+		}
+		p.bb.addPoint(pt)
+	case "DA":
+		return p.parseArcByAngles(arg)
+	case "DB":
+		return p.parseArcByCoordinates(arg)
+	case "DC":
+		radius, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return fmt.Errorf("bad circle radius %q: %w", arg, err)
+		}
+		p.bb.addCircle(Circle{Radius: nautMilesToMeters(radius), Centre: p.centre})
+	}
+
+	return nil
+}
+
+// start begins a new feature for an AC record, finishing off the previous one.
+func (p *openAirParser) start(class string) {
+	p.finish()
+
+	feat := Feature{Class: class}
+	if t, ok := openAirClasses[class]; ok {
+		feat.Class = ""
+		feat.Type = t
+	}
+	// A record without AL or AH records is taken to extend from the surface, or without limit.
+	feat.Geometry = []Volume{{
+		Class:      feat.Class,
+		Type:       feat.Type,
+		Lower:      Altitude{Unit: Feet, Reference: RefSFC},
+		Upper:      Altitude{Unit: Feet, Reference: RefUNL},
+		Activation: Activation{Kind: ActivationH24},
+	}}
+
+	p.feat = &feat
+	p.bb = boundaryBuilder{vol: &p.feat.Geometry[0]}
+	p.clockwise = true
+}
+
+func (p *openAirParser) finish() {
+	if p.feat == nil {
+		return
+	}
+
+	if strings.TrimSpace(p.feat.Name) == "" {
+		p.feat.Name = unnamedOpenAir
+	}
+	p.feat.ID = resolveFeatureID("", p.feat.Name, len(p.features))
+	vol := &p.feat.Geometry[0]
+	vol.ID = p.feat.ID
+	vol.Name = p.feat.Name
+	vol.Type = p.feat.Type
+	*vol = defaultPolicy.Classify(*vol)
+
+	p.features = append(p.features, *p.feat)
+	p.feat = nil
+}
+
+// parseVariable parses "X=<centre>" and "D=+" or "D=-". Other variables are ignored.
+func (p *openAirParser) parseVariable(arg string) error {
+	parts := strings.SplitN(arg, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("bad variable %q", arg)
+	}
+	name, value := strings.ToUpper(strings.TrimSpace(parts[0])), strings.TrimSpace(parts[1])
+
+	switch name {
+	case "X":
+		centre, err := parseOpenAirCoordinate(value)
+		if err != nil {
+			return err
+		}
+		p.centre = centre
+	case "D":
+		switch value {
+		case "+":
+			p.clockwise = true
+		case "-":
+			p.clockwise = false
+		default:
+			return fmt.Errorf("bad direction %q", value)
+		}
+	}
+
+	return nil
+}
+
+// parseArcByAngles parses "DA radius, startAngle, endAngle", with the radius in nautical miles
+// and the angles in degrees true from the centre.
+func (p *openAirParser) parseArcByAngles(arg string) error {
+	parts := strings.Split(arg, ",")
+	if len(parts) != 3 {
+		return fmt.Errorf("bad arc %q", arg)
+	}
+	var values [3]float64
+	for i := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(parts[i]), 64)
+		if err != nil {
+			return fmt.Errorf("bad arc %q: %w", arg, err)
+		}
+		values[i] = v
+	}
+
+	radius := nautMilesToMeters(values[0])
+	from := destinationPoint(p.centre, values[1], radius)
+	to := destinationPoint(p.centre, values[2], radius)
+	p.bb.addPoint(from)
+	p.bb.addArc(p.centre, radius, p.clockwise, to)
+	return nil
+}
+
+// parseArcByCoordinates parses "DB from, to".
+func (p *openAirParser) parseArcByCoordinates(arg string) error {
+	parts := strings.Split(arg, ",")
+	if len(parts) != 2 {
+		return fmt.Errorf("bad arc %q", arg)
+	}
+	from, err := parseOpenAirCoordinate(parts[0])
+	if err != nil {
+		return err
+	}
+	to, err := parseOpenAirCoordinate(parts[1])
+	if err != nil {
+		return err
+	}
+
+	if from != p.bb.currentPos {
+		p.bb.addPoint(from)
+	}
+	p.bb.addArc(p.centre, WGS84.Distance(p.centre, from), p.clockwise, to)
+	return nil
+}
+
+func parseOpenAirCoordinate(str string) (orb.Point, error) {
+	m := openAirCoordinate.FindStringSubmatch(str)
+	if m == nil {
+		return orb.Point{}, fmt.Errorf("bad point: %#q", str)
+	}
+
+	angle := func(deg, min, sec, hemisphere string) float64 {
+		d, _ := strconv.ParseFloat(deg, 64)
+		m, _ := strconv.ParseFloat(min, 64)
+		s, _ := strconv.ParseFloat(sec, 64) // Optional; "" parses as 0.
+		a := d + m/60 + s/3600
+		if hemisphere == "S" || hemisphere == "s" || hemisphere == "W" || hemisphere == "w" {
+			a = -a
+		}
+		return a
+	}
+
+	return orb.Point{angle(m[5], m[6], m[7], m[8]), angle(m[1], m[2], m[3], m[4])}, nil
+}
+
+// parseOpenAirHeight parses AL and AH records, e.g. "SFC", "GND", "UNL", "FL65", "FL 65",
+// "3500ft", "3500 ft MSL", "3500 ALT", "2000ft AGL", "2000ft GND" and "2000 ft SFC".
+func parseOpenAirHeight(h string) (Altitude, error) {
+	h = strings.ToUpper(strings.TrimSpace(h))
+	switch h {
+	case "0", "GND":
+		return Altitude{Unit: Feet, Reference: RefSFC}, nil
+	case "UNLIM", "UNLIMITED":
+		return Altitude{Unit: Feet, Reference: RefUNL}, nil
+	}
+
+	if strings.HasPrefix(h, "FL") {
+		return parseHeight("FL" + strings.TrimSpace(h[2:]))
+	}
+	if strings.HasSuffix(h, "GND") {
+		h = strings.TrimSuffix(h, "GND") + "AGL"
+	}
+
+	for _, suffix := range []string{"AMSL", "MSL", "ALT"} {
+		if strings.HasSuffix(h, suffix) {
+			h = strings.TrimSpace(strings.TrimSuffix(h, suffix))
+			break
+		}
+	}
+	h = strings.Replace(h, "FT", " FT", 1)
+	h = strings.Replace(h, "AGL", " AGL", 1)
+
+	return parseHeight(h)
+}
@@ -0,0 +1,146 @@
+// Package openair writes airspace in the OpenAir format read by most flight instruments and
+// flight planning software (XCSoar, SeeYou, Flymaster and so on).
+//
+// See http://www.winpilot.com/UsersGuide/UserAirspace.asp for a description of the format.
+package openair
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	airspace "github.com/paulcager/gb-airspace"
+	"github.com/paulmach/orb"
+)
+
+// Write writes every volume of each feature as an OpenAir record. Arcs and circles are written
+// using their original centres, radii and directions where the volume's Boundary is known, so
+// instruments can draw them exactly; otherwise the flattened Polygon or Circle is used.
+func Write(w io.Writer, features []airspace.Feature) error {
+	b := bufio.NewWriter(w)
+	for _, f := range features {
+		for _, v := range f.Geometry {
+			writeVolume(b, v)
+		}
+	}
+	return b.Flush()
+}
+
+func writeVolume(w io.Writer, v airspace.Volume) {
+	fmt.Fprintf(w, "* %s (%d)\n", v.ID, v.Sequence)
+	fmt.Fprintf(w, "AC %s\n", Class(v))
+	fmt.Fprintf(w, "AN %s\n", v.Name)
+	if v.Frequency != "" {
+		fmt.Fprintf(w, "AF %s\n", v.Frequency)
+	}
+	if v.Callsign != "" {
+		fmt.Fprintf(w, "AG %s\n", v.Callsign)
+	}
+	fmt.Fprintf(w, "AL %s\n", Height(v.Lower))
+	fmt.Fprintf(w, "AH %s\n", Height(v.Upper))
+
+	if len(v.Boundary) == 0 {
+		if v.Circle.Radius != 0 {
+			writeCircle(w, v.Circle)
+		}
+		for _, p := range v.Polygon {
+			fmt.Fprintf(w, "DP %s\n", Coordinate(p))
+		}
+	}
+
+	for _, seg := range v.Boundary {
+		switch {
+		case seg.Circle != nil:
+			writeCircle(w, *seg.Circle)
+		case seg.Arc != nil:
+			dir := "+"
+			if !seg.Arc.Clockwise {
+				dir = "-"
+			}
+			fmt.Fprintf(w, "V D=%s\n", dir)
+			fmt.Fprintf(w, "V X=%s\n", Coordinate(seg.Arc.Centre))
+			fmt.Fprintf(w, "DB %s, %s\n", Coordinate(seg.Arc.From), Coordinate(seg.Arc.To))
+		default:
+			for _, p := range seg.Line {
+				fmt.Fprintf(w, "DP %s\n", Coordinate(p))
+			}
+		}
+	}
+
+	fmt.Fprintln(w)
+}
+
+func writeCircle(w io.Writer, c airspace.Circle) {
+	fmt.Fprintf(w, "V X=%s\n", Coordinate(c.Centre))
+	fmt.Fprintf(w, "DC %s\n", strconv.FormatFloat(c.Radius/1852, 'f', -1, 64))
+}
+
+// Class returns the OpenAir airspace class (the AC record) for a volume. Prohibited, restricted
+// and danger areas have classes of their own; otherwise the ICAO class is used.
+func Class(v airspace.Volume) string {
+	switch v.Type {
+	case "P":
+		return "P"
+	case "R", "RAT":
+		return "R"
+	case "D":
+		return "Q"
+	case "CTR", "RMZ", "TMZ":
+		if v.Class == "" {
+			return v.Type
+		}
+	}
+
+	switch {
+	case v.Class != "":
+		return v.Class
+	case v.Danger:
+		return "Q"
+	default:
+		return "G"
+	}
+}
+
+// Height formats an altitude for an AL or AH record, e.g. "SFC", "FL65", "3500ft MSL" or
+// "2000ft AGL".
+func Height(a airspace.Altitude) string {
+	value := strconv.FormatFloat(a.Value, 'f', -1, 64) + string(a.Unit)
+	switch a.Reference {
+	case airspace.RefSFC:
+		return "SFC"
+	case airspace.RefUNL:
+		return "UNL"
+	case airspace.RefFL:
+		return "FL" + strconv.FormatFloat(a.Feet()/100, 'f', -1, 64)
+	case airspace.RefAGL:
+		return value + " AGL"
+	default:
+		return value + " MSL"
+	}
+}
+
+// Coordinate formats a point as degrees, minutes and seconds, e.g. "57:21:53 N 001:58:35 W".
+// Seconds are given to the nearest hundredth where needed, e.g. "50:22:57.5 N 003:37:39.25 W",
+// so that coordinates published with decimal seconds survive the export.
+func Coordinate(p orb.Point) string {
+	return dms(p.Lat(), 2, 'N', 'S') + " " + dms(p.Lon(), 3, 'E', 'W')
+}
+
+func dms(angle float64, degDigits int, positive, negative byte) string {
+	hemisphere := positive
+	if angle < 0 {
+		hemisphere = negative
+		angle = -angle
+	}
+
+	hundredths := int64(math.Round(angle * 360000))
+	seconds := hundredths / 100
+	s := fmt.Sprintf("%0*d:%02d:%02d", degDigits, seconds/3600, seconds/60%60, seconds%60)
+	if frac := hundredths % 100; frac != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%02d", frac), "0")
+	}
+	return fmt.Sprintf("%s %c", s, hemisphere)
+}
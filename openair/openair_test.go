@@ -0,0 +1,159 @@
+package openair
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	airspace "github.com/paulcager/gb-airspace"
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const data = `
+airspace:
+- name: ABERDEEN CTA
+  id: aberdeen-cta
+  type: CTA
+  class: D
+  geometry:
+  - seqno: 1
+    upper: FL115
+    lower: 1500 ft
+    boundary:
+    - line:
+      - 572153N 0015835W
+      - 572100N 0015802W
+      - 572100N 0023356W
+    - arc:
+        dir: cw
+        radius: 10 nm
+        centre: 571834N 0021602W
+        to: 572153N 0015835W
+- name: TEST ATZ
+  id: test-atz
+  type: ATZ
+  geometry:
+  - seqno: 1
+    upper: 2000 ft SFC
+    lower: SFC
+    boundary:
+    - circle:
+        radius: 2 nm
+        centre: 530000N 0013000W
+service:
+- callsign: TEST RADIO
+  frequency: 123.500
+  controls: [test-atz]
+`
+
+const expected = `* aberdeen-cta (1)
+AC D
+AN ABERDEEN CTA
+AL 1500ft MSL
+AH FL115
+DP 57:21:53 N 001:58:35 W
+DP 57:21:00 N 001:58:02 W
+DP 57:21:00 N 002:33:56 W
+V D=+
+V X=57:18:34 N 002:16:02 W
+DB 57:21:00 N 002:33:56 W, 57:21:53 N 001:58:35 W
+
+* test-atz (1)
+AC G
+AN TEST ATZ
+AF 123.500
+AG TEST RADIO
+AL SFC
+AH 2000ft AGL
+V X=53:00:00 N 001:30:00 W
+DC 2
+
+`
+
+func TestWrite(t *testing.T) {
+	features, err := airspace.Decode([]byte(data))
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	require.NoError(t, Write(&b, features))
+	assert.Equal(t, expected, b.String())
+}
+
+func TestWriteWithoutBoundary(t *testing.T) {
+	features := []airspace.Feature{{ID: "poly", Geometry: []airspace.Volume{{
+		ID: "poly", Name: "POLY", Type: "D",
+		Lower:   airspace.Altitude{Unit: airspace.Feet, Reference: airspace.RefSFC},
+		Upper:   airspace.Altitude{Value: 1000, Unit: airspace.Metres, Reference: airspace.RefAMSL},
+		Polygon: orb.Ring{{-1, 52}, {-1, 53}, {-2, 53}},
+	}}}}
+
+	var b bytes.Buffer
+	require.NoError(t, Write(&b, features))
+	assert.Equal(t, "* poly (0)\nAC Q\nAN POLY\nAL SFC\nAH 1000m MSL\nDP 52:00:00 N 001:00:00 W\nDP 53:00:00 N 001:00:00 W\nDP 53:00:00 N 002:00:00 W\n\n", b.String())
+}
+
+func TestClass(t *testing.T) {
+	tests := []struct {
+		name     string
+		volume   airspace.Volume
+		expected string
+	}{
+		{"Prohibited", airspace.Volume{Type: "P"}, "P"},
+		{"Restricted", airspace.Volume{Type: "R"}, "R"},
+		{"Temporary restricted", airspace.Volume{Type: "RAT"}, "R"},
+		{"Danger", airspace.Volume{Type: "D"}, "Q"},
+		{"Class D CTR", airspace.Volume{Type: "CTR", Class: "D"}, "D"},
+		{"Unclassified CTR", airspace.Volume{Type: "CTR"}, "CTR"},
+		{"Radio mandatory zone", airspace.Volume{Type: "RMZ"}, "RMZ"},
+		{"Glider site", airspace.Volume{Type: "GLIDER", Danger: true}, "Q"},
+		{"Gas venting", airspace.Volume{Type: "GVS"}, "G"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Class(tt.volume))
+		})
+	}
+}
+
+func TestCoordinate(t *testing.T) {
+	assert.Equal(t, "50:22:57 N 003:37:39 W", Coordinate(orb.Point{-(3 + 37.0/60 + 39.0/3600), 50 + 22.0/60 + 57.0/3600}))
+	assert.Equal(t, "01:00:00 S 120:00:00 E", Coordinate(orb.Point{119 + 59.0/60 + 59.9999/3600, -1}))
+
+	// Decimal seconds are kept.
+	p, err := airspace.ParseLatLng("502257.5N 0033739.25W")
+	require.NoError(t, err)
+	assert.Equal(t, "50:22:57.5 N 003:37:39.25 W", Coordinate(p))
+	decoded, err := airspace.DecodeOpenAir(strings.NewReader("AC D\nAN TEST\nV X=" + Coordinate(p) + "\nDC 1\n"))
+	require.NoError(t, err)
+	assert.InDelta(t, p.Lon(), decoded[0].Geometry[0].Circle.Centre.Lon(), 1e-9)
+	assert.InDelta(t, p.Lat(), decoded[0].Geometry[0].Circle.Centre.Lat(), 1e-9)
+}
+
+func TestRoundTrip(t *testing.T) {
+	features, err := airspace.Decode([]byte(data))
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	require.NoError(t, Write(&b, features))
+	decoded, err := airspace.DecodeOpenAir(&b)
+	require.NoError(t, err)
+	require.Len(t, decoded, len(features))
+
+	for i := range features {
+		want, got := features[i].Geometry[0], decoded[i].Geometry[0]
+		assert.Equal(t, want.Name, got.Name)
+		assert.Equal(t, want.Lower.FeetAMSL(airspace.StandardConditions), got.Lower.FeetAMSL(airspace.StandardConditions))
+		assert.Equal(t, want.Upper.FeetAMSL(airspace.StandardConditions), got.Upper.FeetAMSL(airspace.StandardConditions))
+		assert.Equal(t, Class(want), Class(got))
+	}
+
+	for _, p := range []orb.Point{{-2.2, 57.4}, {-2.2, 57.2}, {-1.5, 53.01}, {-1.5, 53.1}} {
+		assert.Equal(t,
+			len(airspace.NewIndex(features).Query(p)),
+			len(airspace.NewIndex(decoded).Query(p)),
+			"%v", p)
+	}
+}
@@ -0,0 +1,206 @@
+package airspace
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const openAirData = `
+* Test airspace
+AC D
+AN ABERDEEN CTA
+AL 1500ft MSL
+AH FL115
+DP 57:21:53 N 001:58:35 W
+DP 57:21:00 N 001:58:02 W
+DP 57:21:00 N 002:33:56 W
+V D=+
+V X=57:18:34 N 002:16:02 W
+DB 57:21:00 N 002:33:56 W, 57:21:53 N 001:58:35 W
+
+AC CTR
+AN TEST ATZ
+AF 120.775
+AG SHAWBURY ZONE
+AL SFC
+AH 2000ft AGL
+V X=53:00:00 N 001:30:00 W
+DC 2
+
+AC Q
+AN TEST DANGER
+AL 0
+AH 3000 ALT
+V X=52:00:00 N 001:00:00 W
+V D=-
+DA 5, 90, 0
+DP 52:00:00 N 001:00:00 W
+`
+
+func TestDecodeOpenAir(t *testing.T) {
+	features, err := DecodeOpenAir(strings.NewReader(openAirData))
+	require.NoError(t, err)
+	require.Len(t, features, 3)
+
+	cta := features[0]
+	assert.Equal(t, "aberdeen-cta-0", cta.ID)
+	assert.Equal(t, "ABERDEEN CTA", cta.Name)
+	assert.Equal(t, "D", cta.Class)
+	require.Len(t, cta.Geometry, 1)
+	vol := cta.Geometry[0]
+	assert.Equal(t, "aberdeen-cta-0", vol.ID)
+	assert.Equal(t, Altitude{Value: 1500, Unit: Feet, Reference: RefAMSL}, vol.Lower)
+	assert.Equal(t, Altitude{Value: 11500, Unit: Feet, Reference: RefFL}, vol.Upper)
+	assert.True(t, vol.ClearanceRequired)
+	require.Len(t, vol.Boundary, 2)
+	assert.Len(t, vol.Boundary[0].Line, 3)
+	require.NotNil(t, vol.Boundary[1].Arc)
+	assert.True(t, vol.Boundary[1].Arc.Clockwise)
+	// The radius is measured from the centre to the DB start point.
+	arc := vol.Boundary[1].Arc
+	assert.InDelta(t, WGS84.Distance(arc.Centre, orb.Point{-(2 + 33.0/60 + 56.0/3600), 57.35}), arc.Radius, 1e-6)
+	assert.True(t, isEnclosedBy(orb.Point{-2.2, 57.4}, vol, WGS84))
+
+	atz := features[1]
+	assert.Equal(t, "CTR", atz.Type)
+	assert.Equal(t, "", atz.Class)
+	assert.Equal(t, "120.775", atz.Frequency)
+	assert.Equal(t, "SHAWBURY ZONE", atz.Geometry[0].Callsign)
+	vol = atz.Geometry[0]
+	assert.Equal(t, Altitude{Unit: Feet, Reference: RefSFC}, vol.Lower)
+	assert.Equal(t, Altitude{Value: 2000, Unit: Feet, Reference: RefAGL}, vol.Upper)
+	assert.Equal(t, Circle{Radius: 2 * 1852, Centre: orb.Point{-1.5, 53}}, vol.Circle)
+
+	danger := features[2]
+	assert.Equal(t, "D", danger.Type)
+	vol = danger.Geometry[0]
+	assert.True(t, vol.Danger)
+	assert.Equal(t, Altitude{Unit: Feet, Reference: RefSFC}, vol.Lower)
+	assert.Equal(t, Altitude{Value: 3000, Unit: Feet, Reference: RefAMSL}, vol.Upper)
+	require.NotNil(t, vol.Boundary[0].Line)
+	require.NotNil(t, vol.Boundary[1].Arc)
+	assert.False(t, vol.Boundary[1].Arc.Clockwise)
+	// The quarter circle to the north-east of the centre.
+	assert.True(t, isEnclosedBy(destinationPoint(orb.Point{-1, 52}, 45, 4*1852), vol, WGS84))
+	assert.False(t, isEnclosedBy(destinationPoint(orb.Point{-1, 52}, 225, 4*1852), vol, WGS84))
+}
+
+func TestDecodeOpenAirDefaults(t *testing.T) {
+	features, err := DecodeOpenAir(strings.NewReader(`
+AC D
+AN NAMED
+AH 2000ft
+V X=53:00:00 N 001:30:00 W
+DC 2
+AC R
+AL FL65
+V X=53:00:00 N 001:30:00 W
+DC 2
+AC Q
+AN
+V X=53:00:00 N 001:30:00 W
+DC 2
+`))
+	require.NoError(t, err)
+	require.Len(t, features, 3)
+
+	assert.Equal(t, "named-0", features[0].ID)
+	assert.Equal(t, Altitude{Unit: Feet, Reference: RefSFC}, features[0].Geometry[0].Lower)
+	assert.Equal(t, Altitude{Value: 2000, Unit: Feet, Reference: RefAMSL}, features[0].Geometry[0].Upper)
+
+	assert.Equal(t, "unnamed-1", features[1].ID)
+	assert.Equal(t, "UNNAMED", features[1].Name)
+	assert.Equal(t, "unnamed-1", features[1].Geometry[0].ID)
+	assert.Equal(t, "UNNAMED", features[1].Geometry[0].Name)
+	assert.Equal(t, Altitude{Value: 6500, Unit: Feet, Reference: RefFL}, features[1].Geometry[0].Lower)
+	assert.Equal(t, Altitude{Unit: Feet, Reference: RefUNL}, features[1].Geometry[0].Upper)
+
+	assert.Equal(t, "unnamed-2", features[2].ID)
+}
+
+func TestDecodeOpenAirErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{"Record before AC", "AN TEST\nAC D\n", "line 1: AN record before first AC record"},
+		{"Bad point", "AC D\nDP 57:21 N\n", "line 2: bad point: `57:21 N`"},
+		{"Bad direction", "AC D\nV D=x\n", `line 2: bad direction "x"`},
+		{"Bad arc", "AC D\nDA 5, 90\n", `line 2: bad arc "5, 90"`},
+		{"Bad circle", "AC D\nDC two\n", `line 2: bad circle radius "two"`},
+		{"Bad height", "AC D\nAH FL6O\n", `line 2: invalid height "FL6O"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeOpenAir(strings.NewReader(tt.data))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestParseOpenAirCoordinate(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected orb.Point
+	}{
+		{"57:21:53 N 001:58:35 W", orb.Point{-(1 + 58.0/60 + 35.0/3600), 57 + 21.0/60 + 53.0/3600}},
+		{"57:21:53.5N 1:58:35E", orb.Point{1 + 58.0/60 + 35.0/3600, 57 + 21.0/60 + 53.5/3600}},
+		{"57:21.5 S 001:58.25 W", orb.Point{-(1 + 58.25/60), -(57 + 21.5/60)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p, err := parseOpenAirCoordinate(tt.input)
+			require.NoError(t, err)
+			assert.InDelta(t, tt.expected.Lon(), p.Lon(), 1e-9)
+			assert.InDelta(t, tt.expected.Lat(), p.Lat(), 1e-9)
+		})
+	}
+}
+
+func TestParseOpenAirHeight(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Altitude
+	}{
+		{"SFC", Altitude{Unit: Feet, Reference: RefSFC}},
+		{"GND", Altitude{Unit: Feet, Reference: RefSFC}},
+		{"0", Altitude{Unit: Feet, Reference: RefSFC}},
+		{"UNLIM", Altitude{Unit: Feet, Reference: RefUNL}},
+		{"FL65", Altitude{Value: 6500, Unit: Feet, Reference: RefFL}},
+		{"FL 65", Altitude{Value: 6500, Unit: Feet, Reference: RefFL}},
+		{"3500ft", Altitude{Value: 3500, Unit: Feet, Reference: RefAMSL}},
+		{"3500 ft MSL", Altitude{Value: 3500, Unit: Feet, Reference: RefAMSL}},
+		{"3500 ALT", Altitude{Value: 3500, Unit: Feet, Reference: RefAMSL}},
+		{"2000ft AGL", Altitude{Value: 2000, Unit: Feet, Reference: RefAGL}},
+		{"2000ft GND", Altitude{Value: 2000, Unit: Feet, Reference: RefAGL}},
+		{"2000 ft GND", Altitude{Value: 2000, Unit: Feet, Reference: RefAGL}},
+		{"2000 ft SFC", Altitude{Value: 2000, Unit: Feet, Reference: RefAGL}},
+		{"600m AMSL", Altitude{Value: 600, Unit: Metres, Reference: RefAMSL}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			alt, err := parseOpenAirHeight(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, alt)
+		})
+	}
+}
+
+func TestDecodeAny(t *testing.T) {
+	features, err := DecodeAny([]byte(openAirData))
+	require.NoError(t, err)
+	assert.Len(t, features, 3)
+
+	features, err = DecodeAny([]byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, "aberdeen-cta", features[0].ID)
+}
@@ -0,0 +1,172 @@
+package airspace
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/paulmach/orb"
+	"gopkg.in/yaml.v2"
+)
+
+// Policy decides which airspace needs a clearance to enter, and which is a danger to be
+// avoided or flown with care. What applies depends on who is flying: class E needs no clearance
+// for a VFR pilot, but does for a drone. Policies can be written in YAML, for example:
+//
+//	name: club
+//	clearance_classes: [A, B, C, D]
+//	clearance_types: [ATZ, CTA, CTR, P, R, RAT, TMA]
+//	danger_types: [D, DZ, GLIDER, MATZ]
+type Policy struct {
+	Name string `yaml:"name"`
+	// Classes of airspace that need a clearance, whatever their type.
+	ClearanceClasses []string `yaml:"clearance_classes"`
+	// Types of airspace that need a clearance (or at least should not be entered without one),
+	// whatever their class.
+	ClearanceTypes []string `yaml:"clearance_types"`
+	// Types of airspace that are dangerous, but need no clearance.
+	DangerTypes []string `yaml:"danger_types"`
+}
+
+var (
+	defaultPolicy = &Policy{
+		Name:             "default",
+		ClearanceClasses: []string{"A", "B", "C", "D", "E"},
+		ClearanceTypes:   []string{"ATZ", "AWY", "CTA", "CTR", "MATZ", "P", "R", "RAT", "RMZ", "TMA", "TRA", "TMZ"},
+		DangerTypes:      []string{"AIAA", "D", "D_OTHER", "DZ", "GLIDER", "HIRTA", "LASER", "NOATZ", "UL"},
+	}
+
+	// presets are the named policies returned by LookupPolicy, as selected by the server's
+	// policy= parameter.
+	presets = map[string]*Policy{
+		// The rules this package has always used: every controlled class, and all zones.
+		"default": defaultPolicy,
+
+		// Hang gliders and paragliders may fly VFR in class E (most of it is Scottish
+		// airways) without a clearance, and MATZs are only advisory. Airways, CTAs and TMAs
+		// are left to their class, so those in class E need no clearance.
+		"paraglider": {
+			Name:             "paraglider",
+			ClearanceClasses: []string{"A", "B", "C", "D"},
+			ClearanceTypes:   []string{"ATZ", "CTR", "P", "R", "RAT", "RMZ", "TRA", "TMZ"},
+			DangerTypes:      []string{"AIAA", "D", "D_OTHER", "DZ", "GLIDER", "HIRTA", "LASER", "MATZ", "NOATZ", "UL"},
+		},
+
+		// Powered aircraft flying VFR need a clearance for classes A to D and for ATZs, but not
+		// for class E, so CTAs and TMAs are left to their class. With a radio and transponder,
+		// RMZs and TMZs need only a call or a squawk.
+		"ga-vfr": {
+			Name:             "ga-vfr",
+			ClearanceClasses: []string{"A", "B", "C", "D"},
+			ClearanceTypes:   []string{"ATZ", "CTR", "P", "R", "RAT", "TRA"},
+			DangerTypes:      []string{"AIAA", "D", "D_OTHER", "DZ", "GLIDER", "HIRTA", "LASER", "MATZ", "RMZ", "TMZ"},
+		},
+
+		// Drones need permission to fly in any controlled airspace, in an aerodrome's zone, or
+		// in a danger area.
+		"drone": {
+			Name:             "drone",
+			ClearanceClasses: []string{"A", "B", "C", "D", "E"},
+			ClearanceTypes:   []string{"ATZ", "AWY", "CTA", "CTR", "D", "DZ", "MATZ", "P", "R", "RAT", "RMZ", "TMA", "TRA", "TMZ"},
+			DangerTypes:      []string{"AIAA", "D_OTHER", "GLIDER", "HIRTA", "LASER", "NOATZ", "UL"},
+		},
+	}
+)
+
+// DefaultPolicy returns a copy of the policy used by Decode and the other decoders to set each
+// volume's ClearanceRequired and Danger. Use DecodeWithPolicy or Policy.Apply for other rules.
+func DefaultPolicy() *Policy {
+	return defaultPolicy.clone()
+}
+
+// DecodePolicy decodes a Policy from YAML. Unknown fields are an error, so that a mistyped
+// field name doesn't quietly allow anything.
+func DecodePolicy(data []byte) (*Policy, error) {
+	var p Policy
+	if err := yaml.UnmarshalStrict(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal policy: %w", err)
+	}
+	return &p, nil
+}
+
+// LookupPolicy returns a copy of the named preset: "default", "paraglider", "ga-vfr" or
+// "drone". The copy may be changed without affecting later lookups.
+func LookupPolicy(name string) (*Policy, error) {
+	if p, ok := presets[strings.ToLower(name)]; ok {
+		return p.clone(), nil
+	}
+	var names []string
+	for n := range presets {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown policy %q (want one of %s)", name, strings.Join(names, ", "))
+}
+
+func (p *Policy) clone() *Policy {
+	return &Policy{
+		Name:             p.Name,
+		ClearanceClasses: append([]string(nil), p.ClearanceClasses...),
+		ClearanceTypes:   append([]string(nil), p.ClearanceTypes...),
+		DangerTypes:      append([]string(nil), p.DangerTypes...),
+	}
+}
+
+// ClearanceRequired reports whether airspace of the given class and type needs a clearance.
+func (p *Policy) ClearanceRequired(class, airspaceType string) bool {
+	return containsFold(p.ClearanceClasses, class) || containsFold(p.ClearanceTypes, airspaceType)
+}
+
+// Danger reports whether airspace of the given type is dangerous.
+func (p *Policy) Danger(airspaceType string) bool {
+	return containsFold(p.DangerTypes, airspaceType)
+}
+
+// Classify returns a copy of `v` with ClearanceRequired and Danger set by the policy.
+func (p *Policy) Classify(v Volume) Volume {
+	v.ClearanceRequired = p.ClearanceRequired(v.Class, v.Type)
+	v.Danger = p.Danger(v.Type)
+	return v
+}
+
+// Apply returns a copy of the features with every volume classified by the policy.
+func (p *Policy) Apply(features []Feature) []Feature {
+	applied := make([]Feature, len(features))
+	for i, f := range features {
+		f.Geometry = append([]Volume(nil), f.Geometry...)
+		for j := range f.Geometry {
+			f.Geometry[j] = p.Classify(f.Geometry[j])
+		}
+		applied[i] = f
+	}
+	return applied
+}
+
+// DecodeWithPolicy decodes YAML airspace data like Decode, but classifies it using `policy`
+// rather than the default policy.
+func DecodeWithPolicy(data []byte, policy *Policy) ([]Feature, error) {
+	features, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return policy.Apply(features), nil
+}
+
+// EnclosingVolumesWithPolicy returns every volume enclosing `point`, classified by `policy`.
+// Like EnclosingVolumes, it tests every volume.
+func EnclosingVolumesWithPolicy(point orb.Point, features map[string]Feature, policy *Policy) []Volume {
+	volumes := EnclosingVolumes(point, features)
+	for i := range volumes {
+		volumes[i] = policy.Classify(volumes[i])
+	}
+	return volumes
+}
+
+func containsFold(list []string, s string) bool {
+	for _, l := range list {
+		if strings.EqualFold(l, s) {
+			return true
+		}
+	}
+	return false
+}
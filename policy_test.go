@@ -0,0 +1,107 @@
+package airspace
+
+import (
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicies(t *testing.T) {
+	tests := []struct {
+		policy    string
+		class     string
+		typ       string
+		clearance bool
+		danger    bool
+	}{
+		{"default", "E", "AWY", true, false},
+		{"paraglider", "E", "AWY", false, false},
+		{"paraglider", "E", "CTA", false, false},
+		{"paraglider", "D", "CTA", true, false},
+		{"paraglider", "G", "ATZ", true, false},
+		{"paraglider", "E", "OTHER", false, false},
+		{"paraglider", "G", "MATZ", false, true},
+		{"ga-vfr", "E", "OTHER", false, false},
+		{"ga-vfr", "E", "TMA", false, false},
+		{"ga-vfr", "C", "TMA", true, false},
+		{"ga-vfr", "G", "TMZ", false, true},
+		{"drone", "E", "OTHER", true, false},
+		{"drone", "", "D", true, false},
+		{"Drone", "G", "MATZ", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy+"/"+tt.class+"/"+tt.typ, func(t *testing.T) {
+			p, err := LookupPolicy(tt.policy)
+			require.NoError(t, err)
+			assert.Equal(t, tt.clearance, p.ClearanceRequired(tt.class, tt.typ))
+			assert.Equal(t, tt.danger, p.Danger(tt.typ))
+		})
+	}
+
+	_, err := LookupPolicy("balloon")
+	assert.EqualError(t, err, `unknown policy "balloon" (want one of default, drone, ga-vfr, paraglider)`)
+}
+
+func TestPolicyCopies(t *testing.T) {
+	p, err := LookupPolicy("default")
+	require.NoError(t, err)
+	p.ClearanceClasses[0] = "G"
+	p.DangerTypes = nil
+	DefaultPolicy().ClearanceTypes[0] = "OTHER"
+
+	// Changing a copy affects neither the presets nor the classification used by Decode.
+	q, err := LookupPolicy("default")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultPolicy(), q)
+	assert.Equal(t, "A", q.ClearanceClasses[0])
+	assert.Equal(t, "ATZ", q.ClearanceTypes[0])
+	assert.True(t, q.Danger("D"))
+	assert.False(t, ClearanceRequired(Feature{Class: "G", Type: "OTHER"}))
+}
+
+func TestDecodePolicy(t *testing.T) {
+	p, err := DecodePolicy([]byte(`
+name: club
+clearance_classes: [A, B, C, D]
+clearance_types: [atz, CTR]
+danger_types: [D, MATZ]
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "club", p.Name)
+	assert.True(t, p.ClearanceRequired("G", "ATZ"))
+	assert.False(t, p.ClearanceRequired("E", "MATZ"))
+	assert.True(t, p.Danger("MATZ"))
+
+	_, err = DecodePolicy([]byte("clearance_class: [A]\n"))
+	assert.Error(t, err, "Unknown fields are rejected")
+}
+
+func TestDecodeWithPolicy(t *testing.T) {
+	features, err := Decode([]byte(data))
+	require.NoError(t, err)
+	policy, err := LookupPolicy("drone")
+	require.NoError(t, err)
+	drone, err := DecodeWithPolicy([]byte(data), policy)
+	require.NoError(t, err)
+	require.Equal(t, len(features), len(drone))
+
+	// The default classification, as used by Decode, is unchanged.
+	assert.Equal(t, features, DefaultPolicy().Apply(features))
+
+	byID := make(map[string]Feature)
+	for _, f := range drone {
+		byID[f.ID] = f
+		for _, v := range f.Geometry {
+			assert.Equal(t, policy.ClearanceRequired(v.Class, v.Type), v.ClearanceRequired, v.ID)
+		}
+	}
+	point := orb.Point{-2.2, 57.4}
+	enclosing := EnclosingVolumesWithPolicy(point, byID, policy)
+	require.NotEmpty(t, enclosing)
+	for _, v := range enclosing {
+		assert.True(t, v.ClearanceRequired, v.ID)
+	}
+}
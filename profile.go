@@ -0,0 +1,95 @@
+package airspace
+
+import (
+	"errors"
+	"math"
+
+	"github.com/paulmach/orb"
+)
+
+// Profile is a side view of the airspace along a route: the vertical limits of every volume
+// the route passes through, against the distance along it. It is returned by VerticalProfile
+// and drawn by ProfileToSVG.
+type Profile struct {
+	Route orb.LineString
+	// The length of the route, in metres.
+	Length   float64
+	Sections []ProfileSection
+}
+
+// ProfileSection is one passage of the route through a volume.
+type ProfileSection struct {
+	VolumeID          string
+	Name              string
+	Type              string
+	Class             string
+	ClearanceRequired bool
+	Danger            bool
+	// Distances along the route, in metres, from its first point.
+	Start float64
+	End   float64
+	// The limits as published, and in feet AMSL under the profile's conditions. UpperFeet is
+	// nil if the volume is unlimited.
+	Lower     Altitude
+	Upper     Altitude
+	LowerFeet float64
+	UpperFeet *float64
+}
+
+// VerticalProfile returns the profile of the airspace along `route`, with the sections in the
+// order the route enters them. Limits are converted to feet AMSL using `conditions`; note
+// that the ground elevation is taken to be the same all along the route.
+func (idx *Index) VerticalProfile(route orb.LineString, conditions Conditions) (*Profile, error) {
+	if len(route) < 2 {
+		return nil, errors.New("a route needs at least two points")
+	}
+	intersections, err := idx.IntersectLineString(route, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Profile{
+		Route:    route,
+		Length:   newTrack(route, nil, idx.model).distances[len(route)-1],
+		Sections: make([]ProfileSection, 0, len(intersections)),
+	}
+	for _, in := range intersections {
+		v := in.Volume
+		s := ProfileSection{
+			VolumeID:          v.ID,
+			Name:              v.Name,
+			Type:              v.Type,
+			Class:             v.Class,
+			ClearanceRequired: v.ClearanceRequired,
+			Danger:            v.Danger,
+			Start:             in.EntryDistance,
+			End:               in.ExitDistance,
+			Lower:             v.Lower,
+			Upper:             v.Upper,
+			LowerFeet:         v.Lower.FeetAMSL(conditions),
+		}
+		if upper := v.Upper.FeetAMSL(conditions); !math.IsInf(upper, 0) {
+			s.UpperFeet = &upper
+		}
+		p.Sections = append(p.Sections, s)
+	}
+
+	return p, nil
+}
+
+// VerticalProfile returns the profile of the airspace along `route`. It tests every volume;
+// see Index.VerticalProfile.
+func VerticalProfile(route orb.LineString, conditions Conditions, features map[string]Feature) (*Profile, error) {
+	return scanIndex(features).VerticalProfile(route, conditions)
+}
+
+// At returns the sections overhead at `distance` metres along the route.
+func (p *Profile) At(distance float64) []ProfileSection {
+	var sections []ProfileSection
+	for _, s := range p.Sections {
+		if s.Start <= distance && distance <= s.End {
+			sections = append(sections, s)
+		}
+	}
+	return sections
+}
@@ -0,0 +1,108 @@
+package airspace
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// profileFeatures are a TMA with a step in its base, an ATZ beneath it, and unlimited airspace
+// above, all crossed by profileRoute.
+func profileFeatures() []Feature {
+	box := func(id, typ, class string, minLon, maxLon float64, lower, upper Altitude) Feature {
+		return Feature{ID: id, Geometry: []Volume{{
+			ID: id, Name: id, Type: typ, Class: class, Lower: lower, Upper: upper,
+			Polygon: orb.Bound{Min: orb.Point{minLon, 51.9}, Max: orb.Point{maxLon, 52.1}}.ToRing(),
+		}}}
+	}
+	fl := func(fl float64) Altitude { return Altitude{Value: fl * 100, Unit: Feet, Reference: RefFL} }
+	ft := func(ft float64) Altitude { return Altitude{Value: ft, Unit: Feet, Reference: RefAMSL} }
+	return []Feature{
+		box("tma-low", "TMA", "A", -1.0, -0.5, ft(2500), fl(65)),
+		box("tma-high", "TMA", "A", -0.5, 0, ft(4500), fl(65)),
+		box("upper", "CTA", "C", -2, 1, fl(245), Altitude{Unit: Feet, Reference: RefUNL}),
+		{ID: "atz", Geometry: []Volume{{
+			ID: "atz", Name: "A & B ATZ", Type: "ATZ", Class: "G",
+			Lower:  Altitude{Unit: Feet, Reference: RefSFC},
+			Upper:  Altitude{Value: 2000, Unit: Feet, Reference: RefAGL},
+			Circle: Circle{Radius: 2 * 1852, Centre: orb.Point{-1.3, 52}},
+		}}},
+	}
+}
+
+var profileRoute = orb.LineString{{-1.5, 52}, {0.1, 52}}
+
+func TestVerticalProfile(t *testing.T) {
+	p, err := NewIndex(profileFeatures()).VerticalProfile(profileRoute, Conditions{QNH: 1003, GroundElevation: 300})
+	require.NoError(t, err)
+
+	// A degree of longitude at 52°N is about 68.7 km on the WGS84 ellipsoid.
+	assert.InDelta(t, 1.6*68.68e3, p.Length, 200)
+	var ids []string
+	for _, s := range p.Sections {
+		ids = append(ids, s.VolumeID)
+	}
+	assert.Equal(t, []string{"upper", "atz", "tma-low", "tma-high"}, ids)
+
+	atz := p.Sections[1]
+	assert.InDelta(t, 0.2*68.53e3-2*1852, atz.Start, 100)
+	assert.InDelta(t, 0.2*68.53e3+2*1852, atz.End, 100)
+	assert.Equal(t, 300.0, atz.LowerFeet)
+	require.NotNil(t, atz.UpperFeet)
+	assert.Equal(t, 2300.0, *atz.UpperFeet)
+
+	// Flight levels are converted using the QNH.
+	low := p.Sections[2]
+	require.NotNil(t, low.UpperFeet)
+	assert.InDelta(t, 6500-10.25*27, *low.UpperFeet, 10)
+	assert.Nil(t, p.Sections[0].UpperFeet, "Unlimited")
+	assert.InDelta(t, low.End, p.Sections[3].Start, 1)
+
+	under := p.At(0.75 * 68.53e3)
+	require.Len(t, under, 2)
+	assert.Equal(t, "tma-low", under[1].VolumeID)
+
+	b, err := json.Marshal(p)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"UpperFeet":null`)
+
+	_, err = VerticalProfile(orb.LineString{{0, 52}}, StandardConditions, nil)
+	assert.Error(t, err)
+}
+
+func TestProfileToSVG(t *testing.T) {
+	p, err := NewIndex(profileFeatures()).VerticalProfile(profileRoute, StandardConditions)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	require.NoError(t, ProfileToSVG(p, &b))
+	assertGolden(t, "profile.svg", b.Bytes())
+	assert.NotContains(t, b.String(), "<!-- upper ", "Volumes above maxInterestingHeight should be omitted")
+	assert.Contains(t, b.String(), "<title>A &amp; B ATZ SFC-2000 ft SFC</title>")
+}
+
+func TestProfileToSVGConditions(t *testing.T) {
+	// A base of FL30 is 3,000 ft in the standard atmosphere, but only 2,730 ft when the QNH is
+	// 1003 hPa, which is coloured as a lower base.
+	features := []Feature{{ID: "tma", Geometry: []Volume{{
+		ID: "tma", Name: "tma", Type: "TMA", Class: "D",
+		Lower:   Altitude{Value: 3000, Unit: Feet, Reference: RefFL},
+		Upper:   Altitude{Value: 6500, Unit: Feet, Reference: RefFL},
+		Polygon: orb.Bound{Min: orb.Point{-1, 51.5}, Max: orb.Point{0, 52.5}}.ToRing(),
+	}}}}
+	idx := NewIndex(features)
+
+	colour := func(conditions Conditions) string {
+		p, err := idx.VerticalProfile(profileRoute, conditions)
+		require.NoError(t, err)
+		var b bytes.Buffer
+		require.NoError(t, ProfileToSVG(p, &b))
+		return b.String()
+	}
+	assert.Contains(t, colour(StandardConditions), `fill="blue"`)
+	assert.Contains(t, colour(Conditions{QNH: 1003}), `fill="green"`)
+}
@@ -0,0 +1,155 @@
+package airspace
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// TimeWindow is a period of time. A zero Start or End leaves that end of the window open.
+type TimeWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Contains reports whether `t` is within the window. The start is inclusive and the end
+// exclusive.
+func (w TimeWindow) Contains(t time.Time) bool {
+	if !w.Start.IsZero() && t.Before(w.Start) {
+		return false
+	}
+	if !w.End.IsZero() && !t.Before(w.End) {
+		return false
+	}
+	return true
+}
+
+func (w TimeWindow) String() string {
+	format := func(t time.Time) string {
+		if t.IsZero() {
+			return "..."
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	return format(w.Start) + "/" + format(w.End)
+}
+
+// ValidAt reports whether the volume exists at time `t`, i.e. whether `t` is within one of its
+// Validity windows. Volumes with no windows are always valid.
+func (v Volume) ValidAt(t time.Time) bool {
+	if len(v.Validity) == 0 {
+		return true
+	}
+	for _, w := range v.Validity {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// ratFile is either a single RAT, as published in the rat directory of
+// https://github.com/ahsparrow/airspace, or a list of them.
+type ratFile struct {
+	ratResponse `yaml:",inline"`
+	Rat         []ratResponse
+}
+
+// DecodeRAT decodes Restricted Area (Temporary) definitions. Each RAT becomes a Feature of
+// type "RAT"; use SetValidity to say when it applies and Merge to add it to the main airspace.
+// IDs are generated from the RAT's name and its position in the file, so RATs decoded from
+// different files may have the same ID.
+func DecodeRAT(data []byte) ([]Feature, error) {
+	var r ratFile
+	if err := yaml.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal YAML: %w", err)
+	}
+
+	rats := r.Rat
+	if len(rats) == 0 {
+		if r.Name == "" {
+			return nil, fmt.Errorf("no RAT definitions found")
+		}
+		rats = []ratResponse{r.ratResponse}
+	}
+
+	var features []Feature
+	for i, rat := range rats {
+		feat := Feature{
+			ID:   resolveFeatureID("", rat.Name, i),
+			Name: rat.Name,
+			Type: "RAT",
+		}
+		for _, g := range rat.Geometry {
+			vol, issues := processGeometry(g, feat)
+			if err := strictError(issues); err != nil {
+				return nil, fmt.Errorf("RAT %q: %w", rat.Name, err)
+			}
+			feat.Geometry = append(feat.Geometry, vol)
+		}
+		features = append(features, feat)
+	}
+
+	return features, nil
+}
+
+// LoadRAT fetches and decodes RAT definitions from `url`.
+func LoadRAT(url string) ([]Feature, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeRAT(b)
+}
+
+// LoadRATFile reads and decodes RAT definitions from a file.
+func LoadRATFile(fileName string) ([]Feature, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	b, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeRAT(b)
+}
+
+// SetValidity limits every volume of `features` to the given windows, replacing any windows
+// they already had. The features are updated in place.
+func SetValidity(features []Feature, windows ...TimeWindow) {
+	for i := range features {
+		for j := range features[i].Geometry {
+			features[i].Geometry[j].Validity = append([]TimeWindow(nil), windows...)
+		}
+	}
+}
+
+// Merge combines sets of features, such as the main airspace and some RATs. A feature whose ID
+// is already present replaces the earlier one, in its position; other features are appended
+// in order.
+func Merge(sets ...[]Feature) []Feature {
+	var merged []Feature
+	positions := make(map[string]int)
+	for _, set := range sets {
+		for _, f := range set {
+			if i, ok := positions[f.ID]; ok {
+				merged[i] = f
+				continue
+			}
+			positions[f.ID] = len(merged)
+			merged = append(merged, f)
+		}
+	}
+	return merged
+}
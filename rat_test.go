@@ -0,0 +1,161 @@
+package airspace
+
+import (
+	"testing"
+	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ratData = `
+name: SHOREHAM AIRSHOW
+type: RAT
+geometry:
+- seqno: 1
+  upper: FL65
+  lower: SFC
+  boundary:
+  - circle:
+      radius: 5 nm
+      centre: 505007N 0001750W
+`
+
+const ratListData = `
+rat:
+- name: ROYAL FLIGHT
+  type: RAT
+  geometry:
+  - upper: 2000 ft
+    lower: SFC
+    boundary:
+    - line:
+      - 510000N 0010000W
+      - 510000N 0000000W
+      - 500000N 0000000W
+- name: AIR DISPLAY
+  geometry:
+  - upper: 3000 ft
+    lower: SFC
+    boundary:
+    - circle:
+        radius: 2 nm
+        centre: 520000N 0010000W
+`
+
+func TestDecodeRAT(t *testing.T) {
+	features, err := DecodeRAT([]byte(ratData))
+	require.NoError(t, err)
+	require.Len(t, features, 1)
+
+	f := features[0]
+	assert.Equal(t, "shoreham-airshow-0", f.ID)
+	assert.Equal(t, "SHOREHAM AIRSHOW", f.Name)
+	assert.Equal(t, "RAT", f.Type)
+	require.Len(t, f.Geometry, 1)
+	vol := f.Geometry[0]
+	assert.Equal(t, "RAT", vol.Type)
+	assert.True(t, vol.ClearanceRequired)
+	assert.Equal(t, Altitude{Value: 6500, Unit: Feet, Reference: RefFL}, vol.Upper)
+	assert.Equal(t, 5*1852.0, vol.Circle.Radius)
+	assert.Empty(t, vol.Validity)
+}
+
+func TestDecodeRATList(t *testing.T) {
+	features, err := DecodeRAT([]byte(ratListData))
+	require.NoError(t, err)
+	require.Len(t, features, 2)
+
+	assert.Equal(t, "royal-flight-0", features[0].ID)
+	assert.Equal(t, "air-display-1", features[1].ID)
+	assert.Equal(t, "RAT", features[1].Type)
+	assert.Len(t, features[0].Geometry[0].Polygon, 3)
+}
+
+func TestDecodeRATErrors(t *testing.T) {
+	_, err := DecodeRAT([]byte("airspace: []\n"))
+	assert.EqualError(t, err, "no RAT definitions found")
+
+	_, err = DecodeRAT([]byte("name: [\n"))
+	assert.Error(t, err)
+
+	_, err = DecodeRAT([]byte(`
+name: BAD
+geometry:
+- boundary:
+  - line:
+    - not a point
+`))
+	assert.Error(t, err)
+}
+
+func TestTimeWindow(t *testing.T) {
+	start := time.Date(2024, 8, 24, 12, 0, 0, 0, time.UTC)
+	end := start.Add(4 * time.Hour)
+
+	tests := []struct {
+		name     string
+		window   TimeWindow
+		t        time.Time
+		expected bool
+	}{
+		{"Open", TimeWindow{}, start, true},
+		{"Before", TimeWindow{Start: start, End: end}, start.Add(-time.Second), false},
+		{"At start", TimeWindow{Start: start, End: end}, start, true},
+		{"During", TimeWindow{Start: start, End: end}, start.Add(time.Hour), true},
+		{"At end", TimeWindow{Start: start, End: end}, end, false},
+		{"Open start", TimeWindow{End: end}, start.Add(-24 * time.Hour), true},
+		{"Open end", TimeWindow{Start: start}, end.Add(24 * time.Hour), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.window.Contains(tt.t))
+		})
+	}
+
+	assert.Equal(t, "2024-08-24T12:00:00Z/...", TimeWindow{Start: start}.String())
+}
+
+func TestSetValidity(t *testing.T) {
+	features, err := DecodeRAT([]byte(ratData))
+	require.NoError(t, err)
+
+	start := time.Date(2024, 8, 24, 12, 0, 0, 0, time.UTC)
+	SetValidity(features, TimeWindow{Start: start, End: start.Add(4 * time.Hour)})
+
+	vol := features[0].Geometry[0]
+	assert.False(t, vol.ValidAt(start.Add(-time.Hour)))
+	assert.True(t, vol.ValidAt(start.Add(time.Hour)))
+	assert.False(t, vol.ValidAt(start.Add(5*time.Hour)))
+	assert.True(t, Volume{}.ValidAt(start))
+
+	// Each volume has its own copy of the windows.
+	features = []Feature{{Geometry: []Volume{{}, {}}}}
+	SetValidity(features, TimeWindow{Start: start})
+	features[0].Geometry[0].Validity[0].Start = start.Add(time.Hour)
+	assert.Equal(t, start, features[0].Geometry[1].Validity[0].Start)
+}
+
+func TestMerge(t *testing.T) {
+	main := []Feature{{ID: "a", Name: "A"}, {ID: "b", Name: "B"}}
+	rats := []Feature{{ID: "r", Name: "R"}, {ID: "b", Name: "B2"}}
+
+	merged := Merge(main, rats)
+	require.Len(t, merged, 3)
+	assert.Equal(t, "A", merged[0].Name)
+	assert.Equal(t, "B2", merged[1].Name)
+	assert.Equal(t, "R", merged[2].Name)
+	assert.Equal(t, "B", main[1].Name)
+
+	// Merged RATs can be found by point queries.
+	features, err := Decode([]byte(data))
+	require.NoError(t, err)
+	ratFeatures, err := DecodeRAT([]byte(ratData))
+	require.NoError(t, err)
+	idx := NewIndex(Merge(features, ratFeatures))
+	vols := idx.Query(orb.Point{-(17.0/60 + 50.0/3600), 50 + 50.0/60 + 7.0/3600})
+	require.Len(t, vols, 1)
+	assert.Equal(t, "shoreham-airshow-0", vols[0].ID)
+}
@@ -0,0 +1,157 @@
+package airspace
+
+import (
+	"sort"
+
+	"github.com/paulmach/orb"
+)
+
+// Types of the volumes describing where an area service, rather than a unit controlling
+// airspace, can be contacted. Such volumes can be included in yaixm data as features of type
+// OTHER with one of these local types, and a service giving the callsign and frequency.
+const (
+	// ServiceLARS is the coverage of a Lower Airspace Radar Service unit.
+	ServiceLARS = "LARS"
+	// ServiceFIS is a Flight Information Service sector.
+	ServiceFIS = "FIS"
+)
+
+// fallbackFIS are rough Flight Information Service sectors, used by Index.Services when no
+// sector in the airspace data covers the point. The boundary between the London and Scottish FIRs is taken to be
+// 55°N, which is only approximate, and only each unit's main frequency is given.
+var fallbackFIS = []Volume{
+	fisSector("london-information", "LONDON INFORMATION", "124.600", orb.Bound{Min: orb.Point{-12, 48}, Max: orb.Point{5, 55}}),
+	fisSector("scottish-information", "SCOTTISH INFORMATION", "119.875", orb.Bound{Min: orb.Point{-12, 55}, Max: orb.Point{5, 62}}),
+}
+
+// FallbackFIS returns a copy of the approximate FIS sectors that Index.Services falls back on
+// when no volume of type ServiceFIS in the airspace data covers the point. Load FIS sectors
+// with the data for accurate boundaries and frequencies.
+func FallbackFIS() []Volume {
+	sectors := make([]Volume, len(fallbackFIS))
+	for i, v := range fallbackFIS {
+		v.Polygon = append(orb.Ring(nil), v.Polygon...)
+		sectors[i] = v
+	}
+	return sectors
+}
+
+func fisSector(id, callsign, frequency string, b orb.Bound) Volume {
+	return Volume{
+		ID:         id,
+		Name:       callsign,
+		Type:       ServiceFIS,
+		Class:      "G",
+		Lower:      Altitude{Unit: Feet, Reference: RefSFC},
+		Upper:      Altitude{Value: 19500, Unit: Feet, Reference: RefFL},
+		Polygon:    b.ToRing(),
+		Callsign:   callsign,
+		Frequency:  frequency,
+		Activation: Activation{Kind: ActivationH24},
+	}
+}
+
+// Service is an air traffic service to contact, as returned by Index.Services.
+type Service struct {
+	Callsign  string
+	Frequency string
+	// The type of the volume the service is for: e.g. ATZ or MATZ for the unit controlling
+	// it, or ServiceLARS or ServiceFIS.
+	Type string
+	// The volume's ID and name.
+	VolumeID string
+	Name     string
+	// Whether the point is inside the volume and, if not, the distance (in metres) to it.
+	Inside   bool
+	Distance float64
+}
+
+// Services returns the services a pilot at `point` and `altitude` should contact: first those
+// controlling the airspace they are in, then those controlling airspace within `radius`
+// metres at their altitude (nearest first), then any LARS unit, and finally the FIS. Each
+// callsign and frequency is only returned once. If `filter` is not nil, only volumes for which
+// it returns true are considered.
+//
+// LARS units are only returned if the data includes their coverage; yaixm publishes none. FIS
+// sectors come from the data too, or from FallbackFIS if none in the data covers the point.
+func (idx *Index) Services(point orb.Point, altitude Altitude, radius float64, filter func(Volume) bool) []Service {
+	var found []Service
+	add := func(vol Volume) {
+		if vol.Frequency == "" || (filter != nil && !filter(vol)) {
+			return
+		}
+		p := proximity(point, altitude, vol, idx.model)
+		if p.VerticalSeparation != 0 || (!p.Inside && p.Distance > radius) {
+			return
+		}
+		s := Service{
+			Callsign:  vol.Callsign,
+			Frequency: vol.Frequency,
+			Type:      vol.Type,
+			VolumeID:  vol.ID,
+			Name:      vol.Name,
+			Inside:    p.Inside,
+		}
+		if !p.Inside {
+			s.Distance = p.Distance
+		}
+		found = append(found, s)
+	}
+
+	idx.candidates(boundAround(point, radius), add)
+	hasFIS := false
+	for _, s := range found {
+		hasFIS = hasFIS || s.Type == ServiceFIS
+	}
+	if !hasFIS {
+		for _, vol := range fallbackFIS {
+			add(vol)
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool { return found[i].less(found[j]) })
+	services := make([]Service, 0, len(found))
+	seen := make(map[[2]string]bool)
+	for _, s := range found {
+		key := [2]string{s.Callsign, s.Frequency}
+		if !seen[key] {
+			seen[key] = true
+			services = append(services, s)
+		}
+	}
+	return services
+}
+
+// ServicesAt returns the services to contact at `point`. It tests every volume; see
+// Index.Services.
+func ServicesAt(point orb.Point, altitude Altitude, radius float64, filter func(Volume) bool, features map[string]Feature) []Service {
+	return scanIndex(features).Services(point, altitude, radius, filter)
+}
+
+// rank orders services by how directly they concern the pilot: controlled airspace they are
+// in, then controlled airspace nearby, then LARS, then FIS.
+func (s Service) rank() int {
+	switch {
+	case s.Type == ServiceFIS:
+		return 3
+	case s.Type == ServiceLARS:
+		return 2
+	case !s.Inside:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (s Service) less(t Service) bool {
+	if s.rank() != t.rank() {
+		return s.rank() < t.rank()
+	}
+	if s.Distance != t.Distance {
+		return s.Distance < t.Distance
+	}
+	if s.Callsign != t.Callsign {
+		return s.Callsign < t.Callsign
+	}
+	return s.VolumeID < t.VolumeID
+}
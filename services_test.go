@@ -0,0 +1,83 @@
+package airspace
+
+import (
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServices(t *testing.T) {
+	sfc := Altitude{Unit: Feet, Reference: RefSFC}
+	circle := func(id, typ string, lat, radius float64, upper float64, callsign, frequency string) Feature {
+		return Feature{ID: id, Geometry: []Volume{{
+			ID:        id,
+			Name:      id,
+			Type:      typ,
+			Circle:    Circle{Radius: radius, Centre: orb.Point{-2.67, lat}},
+			Lower:     sfc,
+			Upper:     Altitude{Value: upper, Unit: Feet, Reference: RefAMSL},
+			Callsign:  callsign,
+			Frequency: frequency,
+		}}}
+	}
+	idx := NewIndex([]Feature{
+		circle("shawbury-matz", "MATZ", 52.79, 5*1852, 3000, "SHAWBURY ZONE", "120.775"),
+		circle("shawbury-lars", ServiceLARS, 52.79, 30*1852, 10000, "SHAWBURY ZONE", "120.775"),
+		circle("ternhill-matz", "MATZ", 52.95, 5*1852, 3000, "TERNHILL ZONE", "122.100"),
+		circle("sleap-atz", "ATZ", 52.84, 2*1852, 2000, "SLEAP RADIO", "118.650"),
+		circle("far-atz", "ATZ", 53.5, 2*1852, 2000, "FAR RADIO", "123.456"),
+		circle("silent", "ATZ", 52.8, 2*1852, 2000, "", ""),
+	})
+	point := orb.Point{-2.67, 52.80}
+
+	callsigns := func(services []Service) []string {
+		var c []string
+		for _, s := range services {
+			c = append(c, s.Callsign)
+		}
+		return c
+	}
+
+	services := idx.Services(point, Altitude{Value: 1500, Unit: Feet, Reference: RefAMSL}, 10*1852, nil)
+	assert.Equal(t, []string{"SHAWBURY ZONE", "SLEAP RADIO", "TERNHILL ZONE", "LONDON INFORMATION"}, callsigns(services))
+	assert.True(t, services[0].Inside)
+	assert.Equal(t, "shawbury-matz", services[0].VolumeID)
+	assert.Equal(t, "MATZ", services[0].Type)
+	assert.False(t, services[1].Inside)
+	assert.InDelta(t, 4449-2*1852, services[1].Distance, 5)
+	assert.Equal(t, ServiceFIS, services[3].Type)
+
+	// Above the zones, only the LARS and FIS apply.
+	services = idx.Services(point, Altitude{Value: 5000, Unit: Feet, Reference: RefAMSL}, 10*1852, nil)
+	assert.Equal(t, []string{"SHAWBURY ZONE", "LONDON INFORMATION"}, callsigns(services))
+	assert.Equal(t, ServiceLARS, services[0].Type)
+
+	services = idx.Services(point, sfc, 10*1852, func(v Volume) bool { return v.Type != "MATZ" })
+	assert.Equal(t, []string{"SLEAP RADIO", "SHAWBURY ZONE", "LONDON INFORMATION"}, callsigns(services))
+
+	services = NewIndex(nil).Services(orb.Point{-4, 57}, sfc, 1852, nil)
+	assert.Equal(t, []string{"SCOTTISH INFORMATION"}, callsigns(services))
+}
+
+func TestServicesFIS(t *testing.T) {
+	sfc := Altitude{Unit: Feet, Reference: RefSFC}
+	fis := fisSector("london-north", "LONDON INFORMATION", "125.475", orb.Bound{Min: orb.Point{-3, 52}, Max: orb.Point{-2, 53}})
+	features := map[string]Feature{fis.ID: {ID: fis.ID, Type: ServiceFIS, Geometry: []Volume{fis}}}
+
+	// FIS sectors in the data replace the fallback.
+	services := ServicesAt(orb.Point{-2.67, 52.8}, sfc, 1852, nil, features)
+	assert.Len(t, services, 1)
+	assert.Equal(t, "125.475", services[0].Frequency)
+
+	// Elsewhere, the fallback is used.
+	services = ServicesAt(orb.Point{-1, 52.8}, sfc, 1852, nil, features)
+	assert.Len(t, services, 1)
+	assert.Equal(t, "124.600", services[0].Frequency)
+
+	// The fallback can't be changed by callers.
+	FallbackFIS()[0].Frequency = "121.500"
+	FallbackFIS()[0].Polygon[0] = orb.Point{}
+	assert.Equal(t, fallbackFIS, FallbackFIS())
+	assert.Equal(t, orb.Point{-12, 48}, fallbackFIS[0].Polygon[0])
+}
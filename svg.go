@@ -3,13 +3,11 @@ package airspace
 import (
 	"fmt"
 	"io"
-	"log"
 	"math"
-	"strconv"
 	"strings"
 	"text/template"
 
-	"github.com/kr/pretty"
+	"github.com/paulmach/orb"
 )
 
 const (
@@ -35,6 +33,9 @@ var (
 	widthNautMiles  = (maxLon - minLon) * degToNautMileX
 )
 
+// ToSVG draws the volumes of each feature as an SVG image covering the UK. Polygons are drawn
+// as paths and circles as circles, coloured according to the volume's class and lower limit.
+// Volumes whose base is above maxInterestingHeight are omitted.
 func ToSVG(features []Feature, w io.Writer) error {
 	// The image has origin (0,0) in NW corner. All dimensions are in nautical miles.
 
@@ -50,53 +51,82 @@ func ToSVG(features []Feature, w io.Writer) error {
 
 	t := template.Must(template.New("airspace").Funcs(funcMap).Parse(tmplt))
 	return t.Execute(w, params)
-	//_=t
-	//_=params
-	//w.Write([]byte(test1))
-	//return nil
+}
+
+// ProfileToSVG draws a Profile as an SVG cross-section: distance along the route across, in
+// nautical miles, and altitude up, in hundreds of feet, with a grid line every 1,000 feet.
+// Each section is a box from its lower to its upper limit, coloured as by ToSVG but using the
+// profile's conditions rather than the standard atmosphere. As with
+// ToSVG, sections whose base is above maxInterestingHeight are omitted, and the others are
+// cut off there.
+func ProfileToSVG(p *Profile, w io.Writer) error {
+	type box struct {
+		ProfileSection
+		X, Y, Width, Height string
+	}
+
+	top := float64(maxInterestingHeight)
+	var boxes []box
+	for _, s := range p.Sections {
+		if s.LowerFeet >= top {
+			continue
+		}
+		upper := top
+		if s.UpperFeet != nil && *s.UpperFeet < top {
+			upper = *s.UpperFeet
+		}
+		boxes = append(boxes, box{
+			ProfileSection: s,
+			X:              formatSVG(s.Start / 1852),
+			Y:              formatSVG((top - upper) / 100),
+			Width:          formatSVG((s.End - s.Start) / 1852),
+			Height:         formatSVG((upper - math.Max(s.LowerFeet, 0)) / 100),
+		})
+	}
+
+	var grid []string
+	for ft := 0.0; ft <= top; ft += 1000 {
+		grid = append(grid, formatSVG((top-ft)/100))
+	}
+
+	params := map[string]interface{}{
+		"width":  formatSVG(p.Length / 1852),
+		"height": formatSVG(top / 100),
+		"grid":   grid,
+		"boxes":  boxes,
+	}
+
+	t := template.Must(template.New("profile").Funcs(funcMap).Parse(profileTemplate))
+	return t.Execute(w, params)
 }
 
 var funcMap = template.FuncMap{
 	// x converts a longitude to nautical miles from the origin
 	"x": xPos,
-	// y converts a longitude to nautical miles from the origin
-	"y":             yPos,
-	"d":             decodeDistance,
-	"pretty":        func(obj interface{}) string { return pretty.Sprint(obj) },
-	"height":        height,
+	// y converts a latitude to nautical miles from the origin
+	"y": yPos,
+	// nm converts metres to nautical miles
+	"nm":            func(m float64) string { return formatSVG(m / 1852) },
 	"colourise":     colourise,
-	"isInteresting": func(h string) bool { return height(h) <= maxInterestingHeight },
-	//"polygon":       polygon,
+	"colouriseFeet": colouriseFeet,
+	"isInteresting": func(h Altitude) bool { return h.FeetAMSL(StandardConditions) <= maxInterestingHeight },
+	"path":          path,
+	// comment joins and escapes its arguments, ensuring they cannot end an XML comment.
+	"comment": func(args ...interface{}) string {
+		s := template.HTMLEscapeString(strings.TrimSpace(fmt.Sprintln(args...)))
+		return strings.ReplaceAll(s, "--", "- -")
+	},
 }
 
-func xPos(x float64) float64 { return (x - minLon) * degToNautMileX }
-func yPos(y float64) float64 { return (maxLat - y) * degToNautMileY }
+func xPos(x float64) string { return formatSVG((x - minLon) * degToNautMileX) }
+func yPos(y float64) string { return formatSVG((maxLat - y) * degToNautMileY) }
 
-func height(h string) float64 {
-	h = strings.ToUpper(strings.TrimSpace(h))
-	if h == "" || h == "SFC" {
-		return 0
-	}
-
-	if strings.HasPrefix(h, "FL") {
-		// Flight level.
-		f, err := strconv.ParseFloat(h[2:], 64)
-		if err != nil {
-			log.Printf("Could not parse flight levele %#q: %s\n", h, err)
-		}
-		return f * 100 // Standard pressure and so on.
-	}
-
-	h = strings.TrimSpace(strings.TrimSuffix(h, "FT"))
-	f, err := strconv.ParseFloat(h, 64)
-	if err != nil {
-		log.Printf("Could not parse height %#q: %s\n", h, err)
-	}
-	return f
+func formatSVG(f float64) string {
+	return fmt.Sprintf("%.6f", f)
 }
 
 func chooseColour(featureType string, class string, h float64) (string, float64) {
-	if !prohibitedAirspaceClasses[class] {
+	if !containsFold(defaultPolicy.ClearanceClasses, class) {
 		return "black", 0.05
 	}
 
@@ -119,77 +149,54 @@ func chooseColour(featureType string, class string, h float64) (string, float64)
 //  localtype: MATZ
 //  controltype: MILITARY.
 
-func colourise(featureType string, class string, hStr string) string {
-	colour, opacity := chooseColour(featureType, class, height(hStr))
-	return fmt.Sprintf(`fill="%s" opacity="%f"`, colour, opacity)
+func colourise(featureType string, class string, h Altitude) string {
+	return colouriseFeet(featureType, class, h.FeetAMSL(StandardConditions))
 }
 
-//func polygon(featureType string, class string, h string, bounds []Boundary) string {
-//	colour, opacity := chooseColour(featureType, class, height(h))
-//	b := new(bytes.Buffer)
-//	b.WriteString(`<path d="`)
-//	segs := 0
-//	for _, bound := range bounds {
-//		if len(bound.Line) > 0 {
-//			for _, p := range bound.Line {
-//				if segs == 0 {
-//					fmt.Fprintf(b, "M %f %f ", xPos(p.X()), yPos(p.Y()))
-//				} else {
-//					fmt.Fprintf(b, "L %f %f ", xPos(p.X()), yPos(p.Y()))
-//				}
-//				segs++
-//			}
-//		}
-//		arc := bound.Arc
-//		if arc.Radius != "" {
-//			radius := distance(arc.Radius)
-//			_ = radius
-//			//fmt.Fprintf(b,`A %f %f 0 0 0 0 %f %f`, radius, radius, xPos(arc.To.X()), yPos(arc.To.Y()))
-//			fmt.Fprintf(b,`L %f %f`, xPos(arc.To.X()), yPos(arc.To.Y()))
-//		}
-//	}
-//	fmt.Fprintf(b, `Z" fill="none" stroke="%s" stroke-opacity="%f" stroke-width="0.25" />`, colour, opacity)
-//	return b.String()
-//}
-
-const tmplt = `
-{{ $ := . }}
-<svg viewBox="0 0 {{.width}} {{.height}}" preserveAspectRatio="none" xmlns="http://www.w3.org/2000/svg">
+// colouriseFeet is colourise for a lower limit that has already been converted to feet AMSL.
+func colouriseFeet(featureType string, class string, feet float64) string {
+	colour, opacity := chooseColour(featureType, class, feet)
+	return fmt.Sprintf(`fill="%s" fill-opacity="%f" stroke="%s" stroke-width="0.25"`, colour, opacity, colour)
+}
+
+// path converts a polygon into the "d" attribute of an SVG path.
+func path(polygon orb.Ring) string {
+	b := new(strings.Builder)
+	for i, p := range polygon {
+		if i == 0 {
+			fmt.Fprintf(b, "M %s %s", xPos(p.X()), yPos(p.Y()))
+		} else {
+			fmt.Fprintf(b, " L %s %s", xPos(p.X()), yPos(p.Y()))
+		}
+	}
+	b.WriteString(" Z")
+	return b.String()
+}
+
+const tmplt = `<svg viewBox="0 0 {{printf "%f" .width}} {{printf "%f" .height}}" preserveAspectRatio="none" xmlns="http://www.w3.org/2000/svg">
 {{range .features -}}
-	{{- $feature := . -}}
-	{{range .Geometry -}}
-		{{ $volume := . -}}
-		{{- if isInteresting .Lower -}}
-			<!-- {{.ID}} {{.Name}} {{.Class}} {{.Lower}} -->
-			{{range .Boundary -}}
-				{{if ne "" .Circle.Centre.Text -}}
-					<circle cx="{{x .Circle.Centre.X}}" cy="{{y .Circle.Centre.Y}}" r="{{d .Circle.Radius}}" {{colourise $feature.Type $volume.Class $volume.Lower}}/>
-				{{- end -}}
-			{{- end }}
-			{{- polygon feature.Type .Class .Lower .Boundary -}}
-		{{- end -}}
-	{{end -}}
-{{end}}
-<!-- <rect x="0" y="0" height="{{.height}}" width="{{.width}}" fill="none" stroke="#000000" stroke-width="5"/> -->
+{{range .Geometry -}}
+{{if isInteresting .Lower -}}
+<!-- {{comment .ID .Name .Class .Lower}} -->
+{{if ne .Circle.Radius 0.0 -}}
+<circle cx="{{x .Circle.Centre.X}}" cy="{{y .Circle.Centre.Y}}" r="{{nm .Circle.Radius}}" {{colourise .Type .Class .Lower}}/>
+{{end -}}
+{{if .Polygon -}}
+<path d="{{path .Polygon}}" {{colourise .Type .Class .Lower}}/>
+{{end -}}
+{{end -}}
+{{end -}}
+{{end -}}
 </svg>
 `
-const test1 = `
-
-<svg viewBox="0 0 304.4318715140466 570" preserveAspectRatio="none" xmlns="http://www.w3.org/2000/svg">
-<!-- aberdeen-cta ABERDEEN CTA D 1500 ft -->
-                        <path d="
-                            M 161.881533 97.776923
-                            L 162.336114 99.000000
-                            L 140.699870 99.000000
-							A 0.1 0.1 0 1 161.881533 97.776923
-                            "
-                            fill="none" stroke="green" stroke-opacity="0.100000" stroke-width="0.25" /><!-- aberdeen-cta ABERDEEN CTA D 1500 ft -->
-						<circle cx="161.881533" cy="97.776923" r="1" fill="yellow" opacity="0.5"/>
-						<circle cx="162.336114" cy="99" r="1" fill="green" opacity="0.5"/>
-						<circle cx="140.699870" cy="99" r="1" fill="red" opacity="0.5"/>
 
+const profileTemplate = `<svg viewBox="0 0 {{.width}} {{.height}}" preserveAspectRatio="none" xmlns="http://www.w3.org/2000/svg">
+{{range .grid -}}
+<line x1="0" y1="{{.}}" x2="{{$.width}}" y2="{{.}}" stroke="grey" stroke-width="0.1"/>
+{{end -}}
+{{range .boxes -}}
+<!-- {{comment .VolumeID .Name .Class .Lower .Upper}} -->
+<rect x="{{.X}}" y="{{.Y}}" width="{{.Width}}" height="{{.Height}}" {{colouriseFeet .Type .Class .LowerFeet}}><title>{{html .Name}} {{.Lower}}-{{.Upper}}</title></rect>
+{{end -}}
 </svg>
 `
-
-// A 10.000000 10.000000 0 1 161.881533 97.776923
-//                            L 161.881533 97.776923
@@ -0,0 +1,114 @@
+package airspace
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// assertGolden compares `got` with the named file in testdata, rewriting the file instead if
+// the tests are run with -update.
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	golden := filepath.Join("testdata", name)
+	if *update {
+		require.NoError(t, ioutil.WriteFile(golden, got, 0644))
+	}
+	want, err := ioutil.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+}
+
+func TestToSVG(t *testing.T) {
+	features, err := Decode([]byte(data))
+	require.NoError(t, err)
+	features = append(features,
+		Feature{ID: "test-atz", Name: "TEST ATZ", Type: "ATZ", Geometry: []Volume{{
+			ID: "test-atz", Name: "TEST ATZ", Type: "ATZ", Class: "G",
+			Lower:  Altitude{Unit: Feet, Reference: RefSFC},
+			Upper:  Altitude{Value: 2000, Unit: Feet, Reference: RefAGL},
+			Circle: Circle{Radius: 2 * 1852, Centre: orb.Point{-1.5, 53.0}},
+		}}},
+		Feature{ID: "high-cta", Name: "HIGH CTA", Type: "CTA", Class: "C", Geometry: []Volume{{
+			ID: "high-cta", Name: "HIGH CTA", Type: "CTA", Class: "C",
+			Lower:  Altitude{Value: 19500, Unit: Feet, Reference: RefFL},
+			Upper:  Altitude{Value: 24500, Unit: Feet, Reference: RefFL},
+			Circle: Circle{Radius: 10 * 1852, Centre: orb.Point{-1.0, 52.0}},
+		}}},
+	)
+
+	var b bytes.Buffer
+	require.NoError(t, ToSVG(features, &b))
+	assertGolden(t, "airspace.svg", b.Bytes())
+	assert.NotContains(t, b.String(), "HIGH CTA", "Volumes above maxInterestingHeight should be omitted")
+}
+
+func TestToSVGEscapesNames(t *testing.T) {
+	vol := Volume{
+		ID: "r&d", Name: "R&D <TEST> -- AREA", Type: "D", Class: "",
+		Lower:  Altitude{Unit: Feet, Reference: RefSFC},
+		Upper:  Altitude{Value: 2000, Unit: Feet, Reference: RefAMSL},
+		Circle: Circle{Radius: 1852, Centre: orb.Point{-1.5, 53.0}},
+	}
+	features := []Feature{{ID: vol.ID, Name: vol.Name, Type: vol.Type, Geometry: []Volume{vol}}}
+
+	var b bytes.Buffer
+	require.NoError(t, ToSVG(features, &b))
+	assert.Contains(t, b.String(), "R&amp;D &lt;TEST&gt; - - AREA")
+	assertWellFormed(t, b.Bytes())
+
+	p, err := NewIndex(features).VerticalProfile(orb.LineString{{-1.6, 53.0}, {-1.4, 53.0}}, StandardConditions)
+	require.NoError(t, err)
+	b.Reset()
+	require.NoError(t, ProfileToSVG(p, &b))
+	assert.Contains(t, b.String(), "<title>R&amp;D &lt;TEST&gt; -- AREA")
+	assertWellFormed(t, b.Bytes())
+}
+
+// assertWellFormed checks that `b` is well-formed XML.
+func assertWellFormed(t *testing.T, b []byte) {
+	t.Helper()
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			return
+		}
+		require.NoError(t, err)
+	}
+}
+
+func TestChooseColour(t *testing.T) {
+	tests := []struct {
+		name    string
+		class   string
+		h       float64
+		colour  string
+		opacity float64
+	}{
+		{"Uncontrolled", "G", 0, "black", 0.05},
+		{"Surface", "D", 0, "red", 0.25},
+		{"Low base", "D", 500, "red", 0.1},
+		{"Medium base", "D", 1500, "green", 0.1},
+		{"High base", "A", 4500, "blue", 0.1},
+		{"Very high base", "A", 8000, "#000000", 0.1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			colour, opacity := chooseColour("CTA", tt.class, tt.h)
+			assert.Equal(t, tt.colour, colour)
+			assert.Equal(t, tt.opacity, opacity)
+		})
+	}
+}
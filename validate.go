@@ -0,0 +1,221 @@
+package airspace
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+)
+
+// Severity says how serious an Issue is.
+type Severity string
+
+const (
+	// The data is wrong, and anything using it may give the wrong answer.
+	SeverityError Severity = "error"
+	// The data is suspicious, but may be intended.
+	SeverityWarning Severity = "warning"
+)
+
+// Codes identifying the kind of an Issue.
+const (
+	IssueBadCoordinate    = "bad-coordinate"
+	IssueBadHeight        = "bad-height"
+	IssueBadDistance      = "bad-distance"
+	IssueDuplicateID      = "duplicate-id"
+	IssueEmptyGeometry    = "empty-geometry"
+	IssueLowerAboveUpper  = "lower-above-upper"
+	IssueSelfIntersection = "self-intersection"
+	IssueUnclosedRing     = "unclosed-ring"
+	IssueArcRadius        = "arc-radius-mismatch"
+)
+
+// coordinateTolerance is how far, in metres, points that should coincide (such as an arc's end
+// points and its radius) may be apart. Coordinates are only published to the nearest second,
+// which is up to about 30m.
+const coordinateTolerance = 100.0
+
+// flatteningTolerance is how closely, in metres, arcs are flattened when checking boundaries.
+const flatteningTolerance = 10.0
+
+// Issue is a problem found in airspace data, by DecodeLenient or Validate.
+type Issue struct {
+	Severity  Severity
+	Code      string
+	FeatureID string
+	VolumeID  string `json:",omitempty"`
+	Sequence  int    `json:",omitempty"`
+	Message   string
+
+	// For problems found while decoding, the error Decode returns.
+	err error
+}
+
+func (i Issue) String() string {
+	where := i.FeatureID
+	if i.VolumeID != "" && i.VolumeID != i.FeatureID {
+		where += "/" + i.VolumeID
+	}
+	if i.Sequence != 0 {
+		where = fmt.Sprintf("%s#%d", where, i.Sequence)
+	}
+	return fmt.Sprintf("%s: %s: %s (%s)", i.Severity, where, i.Message, i.Code)
+}
+
+// strictError returns the error for the first issue found while decoding, if there is one.
+func strictError(issues []Issue) error {
+	for _, i := range issues {
+		if i.err != nil {
+			return i.err
+		}
+	}
+	return nil
+}
+
+// Validate checks decoded features for problems that don't stop them being decoded: duplicate
+// IDs, missing geometry, a lower limit above the upper, self-intersecting or unclosed
+// boundaries, and arcs whose end points are not on their radius. Use DecodeLenient to find
+// problems with the data that can't be decoded.
+func Validate(features []Feature) []Issue {
+	var issues []Issue
+	seen := make(map[string]bool, len(features))
+	for _, f := range features {
+		if seen[f.ID] {
+			issues = append(issues, Issue{Severity: SeverityError, Code: IssueDuplicateID, FeatureID: f.ID,
+				Message: fmt.Sprintf("feature ID %q is used more than once", f.ID)})
+		}
+		seen[f.ID] = true
+
+		if len(f.Geometry) == 0 {
+			issues = append(issues, Issue{Severity: SeverityError, Code: IssueEmptyGeometry, FeatureID: f.ID,
+				Message: "feature has no volumes"})
+		}
+		for _, vol := range f.Geometry {
+			issues = append(issues, validateVolume(f, vol)...)
+		}
+	}
+	return issues
+}
+
+func validateVolume(f Feature, vol Volume) []Issue {
+	var issues []Issue
+	report := func(severity Severity, code, format string, args ...interface{}) {
+		issues = append(issues, Issue{
+			Severity:  severity,
+			Code:      code,
+			FeatureID: f.ID,
+			VolumeID:  vol.ID,
+			Sequence:  vol.Sequence,
+			Message:   fmt.Sprintf(format, args...),
+		})
+	}
+
+	lower, upper := vol.Lower.FeetAMSL(StandardConditions), vol.Upper.FeetAMSL(StandardConditions)
+	if lower > upper {
+		// Comparing e.g. AGL with AMSL depends on the ground elevation, so may be intended.
+		severity := SeverityError
+		if vol.Lower.Reference != vol.Upper.Reference && vol.Lower.Reference != RefSFC {
+			severity = SeverityWarning
+		}
+		report(severity, IssueLowerAboveUpper, "lower limit %s is above upper limit %s", vol.Lower, vol.Upper)
+	}
+
+	if vol.Circle.Radius > 0 {
+		return issues
+	}
+	// Polygon's 10° chords can cut across a neighbouring line, so check a closer fit.
+	ring := distinctPoints(vol.Flatten(flatteningTolerance))
+	if len(ring) < 3 {
+		report(SeverityError, IssueEmptyGeometry, "boundary has %d distinct points", len(ring))
+		return issues
+	}
+
+	for _, s := range vol.Boundary {
+		if s.Arc == nil {
+			continue
+		}
+		for _, p := range []orb.Point{s.Arc.From, s.Arc.To} {
+			if d := WGS84.Distance(s.Arc.Centre, p); math.Abs(d-s.Arc.Radius) > coordinateTolerance {
+				report(SeverityWarning, IssueArcRadius, "arc end point %v is %.0fm from its centre, but the radius is %.0fm", p, d, s.Arc.Radius)
+			}
+		}
+	}
+
+	// yaixm boundaries are closed implicitly by a straight line, but one ending with an arc
+	// should finish where it started.
+	if len(vol.Boundary) > 0 {
+		if last := vol.Boundary[len(vol.Boundary)-1]; last.Arc != nil {
+			if d := WGS84.Distance(last.Arc.To, ring[0]); d > coordinateTolerance {
+				report(SeverityWarning, IssueUnclosedRing, "boundary ends %.0fm from its start", d)
+			}
+		}
+	}
+
+	if p, ok := selfIntersection(ring); ok {
+		report(SeverityError, IssueSelfIntersection, "boundary crosses itself near %v", p)
+	}
+	return issues
+}
+
+// distinctPoints returns the ring without repeated consecutive points, or a closing point.
+func distinctPoints(ring orb.Ring) orb.Ring {
+	var points orb.Ring
+	for _, p := range ring {
+		if len(points) == 0 || !p.Equal(points[len(points)-1]) {
+			points = append(points, p)
+		}
+	}
+	for len(points) > 1 && points[0].Equal(points[len(points)-1]) {
+		points = points[:len(points)-1]
+	}
+	return points
+}
+
+// selfIntersection returns a point at which two non-adjacent edges of the implicitly closed
+// ring cross, if any do. Crossings that cut off no more than a sliver are ignored: an arc
+// whose end is slightly off its radius can overshoot a line that leaves it almost tangentially.
+func selfIntersection(ring orb.Ring) (orb.Point, bool) {
+	n := len(ring)
+	edge := func(i int) (orb.Point, orb.Point) { return ring[i], ring[(i+1)%n] }
+	for i := 0; i < n; i++ {
+		a, b := edge(i)
+		for j := i + 2; j < n; j++ {
+			if i == 0 && j == n-1 {
+				continue
+			}
+			p, q := edge(j)
+			t, ok := segmentIntersection(a, b, p, q)
+			if !ok {
+				continue
+			}
+			x := orb.Point{a[0] + t*(b[0]-a[0]), a[1] + t*(b[1]-a[1])}
+			if !isSliver(ring, i, j, x) {
+				return x, true
+			}
+		}
+	}
+	return orb.Point{}, false
+}
+
+// isSliver reports whether the loop cut off by edges i and j crossing at x (on whichever side
+// of the ring has fewer vertices) is no more than about coordinateTolerance wide.
+func isSliver(ring orb.Ring, i, j int, x orb.Point) bool {
+	n := len(ring)
+	toMetres := func(p orb.Point) orb.Point {
+		return orb.Point{degreesOfLngToMeters(p[0]), degreesOfLatToMeters(p[1])}
+	}
+
+	first, last := i+1, j
+	if j-i > n/2 {
+		first, last = j+1, i+n
+	}
+	loop := orb.Ring{toMetres(x)}
+	for k := first; k <= last; k++ {
+		loop = append(loop, toMetres(ring[k%n]))
+	}
+	loop = append(loop, loop[0])
+
+	// A long thin loop's width is roughly twice its area divided by its perimeter.
+	return 2*math.Abs(planar.Area(loop)) <= coordinateTolerance*planar.Length(loop)
+}
@@ -0,0 +1,111 @@
+package airspace
+
+import (
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateClean(t *testing.T) {
+	features, err := Decode([]byte(data))
+	require.NoError(t, err)
+	assert.Empty(t, Validate(features))
+}
+
+func TestValidate(t *testing.T) {
+	square := orb.Ring{{0, 50}, {0, 51}, {1, 51}, {1, 50}}
+	vol := func(v Volume) Volume {
+		v.ID, v.Sequence = "vol", 1
+		if v.Polygon == nil && v.Circle.Radius == 0 {
+			v.Polygon = square
+		}
+		if v.Upper.Reference == "" {
+			v.Upper = Altitude{Value: 2000, Unit: Feet, Reference: RefAMSL}
+		}
+		return v
+	}
+
+	tests := []struct {
+		name     string
+		features []Feature
+		expected []string
+	}{
+		{"Valid", []Feature{{ID: "a", Geometry: []Volume{vol(Volume{})}}}, nil},
+		{"Duplicate ID", []Feature{{ID: "a", Geometry: []Volume{vol(Volume{})}}, {ID: "a", Geometry: []Volume{vol(Volume{})}}}, []string{IssueDuplicateID}},
+		{"No volumes", []Feature{{ID: "a"}}, []string{IssueEmptyGeometry}},
+		{"Too few points", []Feature{{ID: "a", Geometry: []Volume{vol(Volume{Polygon: orb.Ring{{0, 50}, {0, 51}, {0, 51}, {0, 50}}})}}}, []string{IssueEmptyGeometry}},
+		{"Lower above upper", []Feature{{ID: "a", Geometry: []Volume{vol(Volume{
+			Lower: Altitude{Value: 8500, Unit: Feet, Reference: RefFL},
+		})}}}, []string{IssueLowerAboveUpper}},
+		{"Bow tie", []Feature{{ID: "a", Geometry: []Volume{vol(Volume{Polygon: orb.Ring{{0, 50}, {1, 51}, {1, 50}, {0, 51}}})}}}, []string{IssueSelfIntersection}},
+		{"Arc off radius", []Feature{{ID: "a", Geometry: []Volume{vol(Volume{Boundary: []Segment{
+			{Line: orb.LineString{{0, 50}, {0, 51}}},
+			{Arc: &Arc{Centre: orb.Point{0.5, 50.5}, Radius: 100000, Clockwise: true, From: orb.Point{0, 51}, To: orb.Point{1, 50}}},
+		}})}}}, []string{IssueArcRadius, IssueArcRadius, IssueUnclosedRing}},
+		{"Circle", []Feature{{ID: "a", Geometry: []Volume{vol(Volume{Circle: Circle{Radius: 1000}})}}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var codes []string
+			for _, issue := range Validate(tt.features) {
+				assert.Equal(t, "a", issue.FeatureID)
+				codes = append(codes, issue.Code)
+			}
+			assert.Equal(t, tt.expected, codes)
+		})
+	}
+}
+
+func TestDecodeLenient(t *testing.T) {
+	bad := `
+airspace:
+- name: BAD
+  id: bad
+  type: D
+  geometry:
+  - seqno: 1
+    upper: FLxx
+    lower: SFC
+    boundary:
+    - line:
+      - 520000N 0010000W
+      - not a point
+      - 530000N 0010000W
+      - 530000N 0000000W
+  - seqno: 2
+    upper: 2000 ft
+    lower: SFC
+    boundary:
+    - circle:
+        radius: two nm
+        centre: 520000N 0010000W
+`
+	_, err := Decode([]byte(bad))
+	assert.Error(t, err)
+
+	features, issues, err := DecodeLenient([]byte(bad))
+	require.NoError(t, err)
+	require.Len(t, features, 1)
+	assert.Len(t, features[0].Geometry[0].Polygon, 3)
+
+	require.Len(t, issues, 3)
+	assert.Equal(t, SeverityError, issues[0].Severity)
+	assert.Equal(t, IssueBadHeight, issues[0].Code)
+	assert.Equal(t, "bad", issues[0].FeatureID)
+	assert.Equal(t, 1, issues[0].Sequence)
+	assert.Equal(t, `invalid upper "FLxx": invalid syntax`, issues[0].Message)
+	assert.Equal(t, IssueBadCoordinate, issues[1].Code)
+	assert.Equal(t, IssueBadDistance, issues[2].Code)
+	assert.Equal(t, 2, issues[2].Sequence)
+
+	_, _, err = DecodeLenient([]byte("airspace: [\n"))
+	assert.Error(t, err)
+}
+
+func TestIssueString(t *testing.T) {
+	assert.Equal(t, "error: a/b#2: oops (bad-height)", Issue{Severity: SeverityError, Code: IssueBadHeight, FeatureID: "a", VolumeID: "b", Sequence: 2, Message: "oops"}.String())
+	assert.Equal(t, "warning: a: oops (duplicate-id)", Issue{Severity: SeverityWarning, Code: IssueDuplicateID, FeatureID: "a", VolumeID: "a", Message: "oops"}.String())
+}